@@ -0,0 +1,169 @@
+// Package ast is a typed view of the tree built by kll's Parser. Each
+// construct has its own struct, so tools such as formatters or linters can
+// use fields like CallExpr.Args instead of the positional children of the
+// string tagged kll.Node. From_Nodes converts the parser output.
+package ast
+
+import "github.com/kaklikOf13/kll"
+
+// Pos is the line and column, both starting at 1, where a node begins.
+type Pos struct {
+	Line uint64
+	Col  uint64
+}
+
+func (this Pos) Position() Pos {
+	return this
+}
+
+// Node is implemented by every struct of the package.
+type Node interface {
+	Position() Pos
+}
+
+// File is a parsed script.
+type File struct {
+	Stmts []Node
+}
+
+func (this *File) Position() Pos {
+	if len(this.Stmts) == 0 {
+		return Pos{}
+	}
+	return this.Stmts[0].Position()
+}
+
+type (
+	// Ident is a variable name. When the tree went through kll.Resolve,
+	// Depth and Slot locate it in the frames; Slot is -1 for names looked
+	// up in the module locals and Globals.
+	Ident struct {
+		Pos
+		Name  string
+		Depth int
+		Slot  int
+	}
+	// Literal is a number, string or null written in the source, or a
+	// value folded by kll.Optimize.
+	Literal struct {
+		Pos
+		Value kll.Value
+	}
+	// Empty is the missing expression, as in a bare return.
+	Empty struct {
+		Pos
+	}
+	// BinaryExpr is Left Op Right, Op being one of + - * / == && || in.
+	BinaryExpr struct {
+		Pos
+		Op    string
+		Left  Node
+		Right Node
+	}
+	// UnaryExpr is Op X, Op being -.
+	UnaryExpr struct {
+		Pos
+		Op string
+		X  Node
+	}
+	ParenExpr struct {
+		Pos
+		X Node
+	}
+	// RangeExpr is Start..Stop, or Start..<Stop when Inclusive is false.
+	RangeExpr struct {
+		Pos
+		Start     Node
+		Stop      Node
+		Inclusive bool
+	}
+	ArrayLit struct {
+		Pos
+		Elems []Node
+	}
+	// SpreadExpr is ...X, in array literals, calls and destructuring.
+	SpreadExpr struct {
+		Pos
+		X Node
+	}
+	// AttrExpr is X.Name.
+	AttrExpr struct {
+		Pos
+		X    Node
+		Name string
+	}
+	// CallExpr is Fun(Args). Keyword arguments are KeywordArg nodes kept
+	// in Args in source order.
+	CallExpr struct {
+		Pos
+		Fun  Node
+		Args []Node
+	}
+	KeywordArg struct {
+		Pos
+		Name  string
+		Value Node
+	}
+	// AssignExpr is Target = Value, Target being an Ident or an AttrExpr.
+	AssignExpr struct {
+		Pos
+		Target Node
+		Value  Node
+	}
+	// VarDecl is var Target = Value, or global when Global is set. Value
+	// is nil when there is no initializer, and Target may be an ArrayLit
+	// when destructuring.
+	VarDecl struct {
+		Pos
+		Global bool
+		Target Node
+		Value  Node
+	}
+	// FuncLit is a function or function* declaration; Name is empty for
+	// anonymous functions.
+	FuncLit struct {
+		Pos
+		Name      string
+		Generator bool
+		Params    []Node
+		Body      *Block
+	}
+	Block struct {
+		Pos
+		Stmts []Node
+	}
+	IfStmt struct {
+		Pos
+		Cond Node
+		Body *Block
+	}
+	// ForStmt is for Target in Iter { Body }.
+	ForStmt struct {
+		Pos
+		Target Node
+		Iter   Node
+		Body   *Block
+	}
+	ReturnStmt struct {
+		Pos
+		Value Node
+	}
+	YieldExpr struct {
+		Pos
+		Value Node
+	}
+	ExistExpr struct {
+		Pos
+		Name *Ident
+	}
+	PointerExpr struct {
+		Pos
+		X Node
+	}
+	// Unknown keeps a kll.Node of a kind this package has no struct for.
+	Unknown struct {
+		Pos
+		Kind     string
+		Children []Node
+	}
+)
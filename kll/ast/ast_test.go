@@ -0,0 +1,221 @@
+package ast_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kaklikOf13/kll"
+	"github.com/kaklikOf13/kll/ast"
+	"github.com/kaklikOf13/kll/format"
+)
+
+// every_kind uses each construct the parser can build.
+const every_kind = `var a = 1
+global g = "s"
+var [b, ...c] = [a, ...[2, 3]]
+var n
+function f(x, y) {
+    return x + y * (2 - 1) / 4
+}
+function* gen() {
+    yield -a
+    return
+}
+a = f(1, y = 2).length
+if exist a && a == 1 || 2 in 0..<3 {
+    n = console.log
+}
+for i in 1..2 {
+    n = function(z) {
+        return z
+    }
+}
+`
+
+func parse(t *testing.T, src string) *ast.File {
+	t.Helper()
+	nodes, err := (&kll.Parser{}).Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ast.From_Nodes(nodes)
+}
+
+// dump writes node without positions, each struct as (Type fields...).
+func dump(node ast.Node) string {
+	var b strings.Builder
+	dump_value(&b, reflect.ValueOf(node))
+	return b.String()
+}
+func dump_value(b *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		if value, ok := v.Interface().(kll.Value); ok {
+			fmt.Fprintf(b, "%s:%s", value.VType(), value.Re_string(""))
+			return
+		}
+		dump_value(b, v.Elem())
+	case reflect.Struct:
+		fmt.Fprintf(b, "(%s", v.Type().Name())
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).Name == "Pos" {
+				continue
+			}
+			b.WriteString(" ")
+			dump_value(b, v.Field(i))
+		}
+		b.WriteString(")")
+	case reflect.Slice:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			dump_value(b, v.Index(i))
+		}
+		b.WriteString("]")
+	default:
+		fmt.Fprintf(b, "%q", fmt.Sprint(v.Interface()))
+	}
+}
+
+func TestFromNodes(t *testing.T) {
+	file := parse(t, every_kind)
+	want := []string{
+		`(VarDecl "false" (Ident "a" "0" "-1") (Literal Number:1))`,
+		`(VarDecl "true" (Ident "g" "0" "-1") (Literal String:s))`,
+		`(VarDecl "false" (ArrayLit [(Ident "b" "0" "-1") (SpreadExpr (Ident "c" "0" "-1"))]) (ArrayLit [(Ident "a" "0" "-1") (SpreadExpr (ArrayLit [(Literal Number:2) (Literal Number:3)]))]))`,
+		`(VarDecl "false" (Ident "n" "0" "-1") nil)`,
+		`(FuncLit "f" "false" [(Ident "x" "0" "-1") (Ident "y" "0" "-1")] (Block [(ReturnStmt (BinaryExpr "+" (Ident "x" "0" "-1") (BinaryExpr "*" (Ident "y" "0" "-1") (BinaryExpr "/" (ParenExpr (BinaryExpr "-" (Literal Number:2) (Literal Number:1))) (Literal Number:4)))))]))`,
+		`(FuncLit "gen" "true" [] (Block [(YieldExpr (UnaryExpr "-" (Ident "a" "0" "-1"))) (ReturnStmt (Empty))]))`,
+		`(AssignExpr (Ident "a" "0" "-1") (AttrExpr (CallExpr (Ident "f" "0" "-1") [(Literal Number:1) (KeywordArg "y" (Literal Number:2))]) "length"))`,
+		`(IfStmt (BinaryExpr "&&" (ExistExpr (Ident "a" "0" "-1")) (BinaryExpr "||" (BinaryExpr "==" (Ident "a" "0" "-1") (Literal Number:1)) (BinaryExpr "in" (Literal Number:2) (RangeExpr (Literal Number:0) (Literal Number:3) "false")))) (Block [(AssignExpr (Ident "n" "0" "-1") (AttrExpr (Ident "console" "0" "-1") "log"))]))`,
+		`(ForStmt (Ident "i" "0" "-1") (RangeExpr (Literal Number:1) (Literal Number:2) "true") (Block [(AssignExpr (Ident "n" "0" "-1") (FuncLit "" "false" [(Ident "z" "0" "-1")] (Block [(ReturnStmt (Ident "z" "0" "-1"))])))]))`,
+	}
+	if len(file.Stmts) != len(want) {
+		t.Fatalf("got %d statements, want %d", len(file.Stmts), len(want))
+	}
+	for i, stmt := range file.Stmts {
+		if got := dump(stmt); got != want[i] {
+			t.Errorf("statement %d:\ngot  %s\nwant %s", i+1, got, want[i])
+		}
+	}
+	seen := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n != nil {
+			seen[reflect.TypeOf(n).Elem().Name()] = true
+		}
+		return true
+	})
+	// PointerExpr has no syntax of its own and Unknown is for the kinds of
+	// nodes this package doesn't know, so the script can't produce them
+	for _, kind := range []string{"File", "Ident", "Literal", "Empty", "BinaryExpr", "UnaryExpr", "ParenExpr", "RangeExpr", "ArrayLit", "SpreadExpr", "AttrExpr", "CallExpr", "KeywordArg", "AssignExpr", "VarDecl", "FuncLit", "Block", "IfStmt", "ForStmt", "ReturnStmt", "YieldExpr", "ExistExpr"} {
+		if !seen[kind] {
+			t.Errorf("the script has no %s", kind)
+		}
+	}
+}
+
+// TestRoundTrip prints the tree back to source, which must parse to the
+// same tree.
+func TestRoundTrip(t *testing.T) {
+	out, err := format.Source(every_kind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != every_kind {
+		t.Errorf("printing changed the source:\n%s", out)
+	}
+	if got, want := dump(parse(t, out)), dump(parse(t, every_kind)); got != want {
+		t.Errorf("the printed source parses to another tree:\ngot  %s\nwant %s", got, want)
+	}
+}
+
+func TestFromNodesKeepsPositions(t *testing.T) {
+	file := parse(t, "var a = 1\nif a {\n    a = f(a)\n}\n")
+	assign := file.Stmts[1].(*ast.IfStmt).Body.Stmts[0].(*ast.AssignExpr)
+	call := assign.Value.(*ast.CallExpr)
+	for _, test := range []struct {
+		node ast.Node
+		want ast.Pos
+	}{
+		{node: file.Stmts[0], want: ast.Pos{Line: 1, Col: 1}},
+		{node: file.Stmts[1], want: ast.Pos{Line: 2, Col: 1}},
+		{node: assign.Target, want: ast.Pos{Line: 3, Col: 5}},
+		{node: call.Args[0], want: ast.Pos{Line: 3, Col: 11}},
+	} {
+		if got := test.node.Position(); got != test.want {
+			t.Errorf("%s at %v, want %v", dump(test.node), got, test.want)
+		}
+	}
+}
+
+func TestFromNodesResolved(t *testing.T) {
+	nodes, err := (&kll.Parser{}).Parse("function f(x) {\n    return function() {\n        return x\n    }\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := nodes[0].Re_string("")
+	kll.Resolve(nodes, nil)
+	inner := ast.From_Nodes(nodes).Stmts[0].(*ast.FuncLit).Body.Stmts[0].(*ast.ReturnStmt).Value.(*ast.FuncLit)
+	x := inner.Body.Stmts[0].(*ast.ReturnStmt).Value.(*ast.Ident)
+	if x.Depth != 1 || x.Slot != 0 {
+		t.Errorf("x is at depth %d, slot %d, want 1, 0", x.Depth, x.Slot)
+	}
+	if nodes[0].Re_string("") == before {
+		t.Errorf("Resolve did not annotate the nodes")
+	}
+	pointer := ast.From_Node(kll.Create_Node([]kll.Value{kll.Create_Node([]kll.Value{kll.Create_String("a")}, "var", 1, 2)}, "pointer", 1, 1))
+	if got := dump(pointer); got != `(PointerExpr (Ident "a" "0" "-1"))` {
+		t.Errorf("pointer: got %s", got)
+	}
+	unknown := ast.From_Node(kll.Create_Node([]kll.Value{kll.Create_Number(1)}, "new kind", 1, 1))
+	if got := dump(unknown); got != `(Unknown "new kind" [(Literal Number:1)])` {
+		t.Errorf("unknown: got %s", got)
+	}
+}
+
+type recorder []string
+
+func (this *recorder) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		*this = append(*this, "end")
+	} else {
+		*this = append(*this, reflect.TypeOf(node).Elem().Name())
+	}
+	return this
+}
+
+func TestWalkOrder(t *testing.T) {
+	file := parse(t, "var a = f(1, k = 2)\nfor [x] in a {\n    x.y = -x\n}\n")
+	var got recorder
+	ast.Walk(&got, file)
+	want := strings.Fields(`File
+		VarDecl Ident end CallExpr Ident end Literal end KeywordArg Literal end end end end
+		ForStmt ArrayLit Ident end end Ident end Block
+			AssignExpr AttrExpr Ident end end UnaryExpr Ident end end end
+		end end
+	end`)
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("visited\n%s\nwant\n%s", strings.Join(got, " "), strings.Join(want, " "))
+	}
+
+	// Inspect doesn't enter the nodes f returns false for
+	names := []string{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			names = append(names, id.Name)
+		}
+		_, block := n.(*ast.Block)
+		return !block
+	})
+	if got := strings.Join(names, " "); got != "a f x a" {
+		t.Errorf("Inspect found %s, want a f x a", got)
+	}
+}
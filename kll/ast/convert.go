@@ -0,0 +1,128 @@
+package ast
+
+import "github.com/kaklikOf13/kll"
+
+// From_Nodes converts the nodes returned by kll.Parser.Parse, before or
+// after kll.Resolve and kll.Optimize, into a File. The nodes are not
+// modified, so the same tree can still be run by the Interpreter.
+func From_Nodes(nodes []kll.Value) *File {
+	return &File{Stmts: from_list(nodes)}
+}
+
+// From_Node converts a single kll.Node. Values that are not nodes become
+// Literals.
+func From_Node(value kll.Value) Node {
+	node, ok := value.(kll.Node)
+	if !ok {
+		return &Literal{Value: value}
+	}
+	pos := Pos{Line: node.Line, Col: node.Col}
+	child := func(i int) Node {
+		if i >= len(node.Value) {
+			return nil
+		}
+		return From_Node(node.Value[i])
+	}
+	switch node.Tp {
+	case "value":
+		return &Literal{Pos: pos, Value: node.Value[0]}
+	case "null":
+		return &Empty{Pos: pos}
+	case "var":
+		return from_var(node)
+	case "+", "-", "*", "/", "==", "&&", "||", "in":
+		return &BinaryExpr{Pos: pos, Op: node.Tp, Left: child(0), Right: child(1)}
+	case "inverse number":
+		return &UnaryExpr{Pos: pos, Op: "-", X: child(0)}
+	case "()":
+		return &ParenExpr{Pos: pos, X: child(0)}
+	case "range":
+		return &RangeExpr{Pos: pos, Start: child(0), Stop: child(1), Inclusive: node.Value[2].Re_bool()}
+	case "array":
+		return &ArrayLit{Pos: pos, Elems: from_list(node.Value)}
+	case "spread":
+		return &SpreadExpr{Pos: pos, X: child(0)}
+	case "get attr":
+		return from_attr(child(0), node.Value[1])
+	case "call":
+		re := &CallExpr{Pos: pos, Fun: child(0)}
+		for _, p := range node.Value[1].(kll.Node).Value {
+			if p := p.(kll.Node); p.Tp == "=" && p.Value[0].(kll.Node).Tp == "var" {
+				re.Args = append(re.Args, &KeywordArg{Pos: Pos{Line: p.Line, Col: p.Col}, Name: p.Value[0].(kll.Node).Value[0].Re_string(""), Value: From_Node(p.Value[1])})
+			} else {
+				re.Args = append(re.Args, From_Node(p))
+			}
+		}
+		return re
+	case "=":
+		return &AssignExpr{Pos: pos, Target: child(0), Value: child(1)}
+	case "create global", "create local":
+		re := &VarDecl{Pos: pos, Global: node.Tp == "create global"}
+		if target := node.Value[0].(kll.Node); target.Tp == "=" {
+			re.Target, re.Value = From_Node(target.Value[0]), From_Node(target.Value[1])
+		} else {
+			re.Target = From_Node(target)
+		}
+		return re
+	case "function", "generator":
+		return &FuncLit{
+			Pos:       pos,
+			Name:      node.Value[0].Re_string(""),
+			Generator: node.Tp == "generator",
+			Params:    from_list(node.Value[1].(kll.Node).Value),
+			Body:      from_block(node.Value[2]),
+		}
+	case "{}":
+		return from_block(node)
+	case "if":
+		return &IfStmt{Pos: pos, Cond: child(0), Body: from_block(node.Value[1])}
+	case "for":
+		return &ForStmt{Pos: pos, Target: child(0), Iter: child(1), Body: from_block(node.Value[2])}
+	case "return":
+		return &ReturnStmt{Pos: pos, Value: child(0)}
+	case "yield":
+		return &YieldExpr{Pos: pos, Value: child(0)}
+	case "exist":
+		if name, ok := child(0).(*Ident); ok {
+			return &ExistExpr{Pos: pos, Name: name}
+		}
+	case "pointer":
+		return &PointerExpr{Pos: pos, X: child(0)}
+	}
+	return &Unknown{Pos: pos, Kind: node.Tp, Children: from_list(node.Value)}
+}
+
+func from_list(values []kll.Value) []Node {
+	re := make([]Node, 0, len(values))
+	for _, v := range values {
+		re = append(re, From_Node(v))
+	}
+	return re
+}
+func from_block(value kll.Value) *Block {
+	node := value.(kll.Node)
+	return &Block{Pos: Pos{Line: node.Line, Col: node.Col}, Stmts: from_list(node.Value)}
+}
+func from_var(node kll.Node) *Ident {
+	re := &Ident{Pos: Pos{Line: node.Line, Col: node.Col}, Name: node.Value[0].Re_string(""), Slot: -1}
+	if len(node.Value) >= 3 {
+		re.Depth, re.Slot = int(node.Value[1].Re_number()), int(node.Value[2].Re_number())
+	}
+	return re
+}
+
+// from_attr nests the name side of a "get attr" node, a.b.c being parsed
+// as a with the path b.c, into AttrExprs around x.
+func from_attr(x Node, name kll.Value) Node {
+	node, ok := name.(kll.Node)
+	if !ok {
+		return x
+	}
+	switch node.Tp {
+	case "var":
+		return &AttrExpr{Pos: Pos{Line: node.Line, Col: node.Col}, X: x, Name: node.Value[0].Re_string("")}
+	case "get attr":
+		return from_attr(from_attr(x, node.Value[0]), node.Value[1])
+	}
+	return &Unknown{Pos: Pos{Line: node.Line, Col: node.Col}, Kind: node.Tp, Children: []Node{x, From_Node(node)}}
+}
@@ -0,0 +1,93 @@
+package ast
+
+// Visitor is called by Walk for each node. If the returned Visitor is not
+// nil, Walk visits the children of node with it and then calls
+// Visit(nil).
+type Visitor interface {
+	Visit(node Node) Visitor
+}
+
+// Children returns the direct children of node in source order.
+func Children(node Node) []Node {
+	re := []Node{}
+	add := func(nodes ...Node) {
+		for _, n := range nodes {
+			if n != nil {
+				re = append(re, n)
+			}
+		}
+	}
+	switch n := node.(type) {
+	case *File:
+		add(n.Stmts...)
+	case *BinaryExpr:
+		add(n.Left, n.Right)
+	case *UnaryExpr:
+		add(n.X)
+	case *ParenExpr:
+		add(n.X)
+	case *RangeExpr:
+		add(n.Start, n.Stop)
+	case *ArrayLit:
+		add(n.Elems...)
+	case *SpreadExpr:
+		add(n.X)
+	case *AttrExpr:
+		add(n.X)
+	case *CallExpr:
+		add(n.Fun)
+		add(n.Args...)
+	case *KeywordArg:
+		add(n.Value)
+	case *AssignExpr:
+		add(n.Target, n.Value)
+	case *VarDecl:
+		add(n.Target, n.Value)
+	case *FuncLit:
+		add(n.Params...)
+		add(n.Body)
+	case *Block:
+		add(n.Stmts...)
+	case *IfStmt:
+		add(n.Cond, n.Body)
+	case *ForStmt:
+		add(n.Target, n.Iter, n.Body)
+	case *ReturnStmt:
+		add(n.Value)
+	case *YieldExpr:
+		add(n.Value)
+	case *ExistExpr:
+		add(n.Name)
+	case *PointerExpr:
+		add(n.X)
+	case *Unknown:
+		add(n.Children...)
+	}
+	return re
+}
+
+// Walk traverses the tree rooted at node depth first.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, c := range Children(node) {
+		Walk(v, c)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (this inspector) Visit(node Node) Visitor {
+	if this(node) {
+		return this
+	}
+	return nil
+}
+
+// Inspect calls f for each node of the tree, skipping the children of the
+// nodes for which it returns false, and with nil after the children.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,138 @@
+package kll
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Benchmark_Script is one program of the benchmark suite. Result is what
+// the last statement must evaluate to on both engines.
+type Benchmark_Script struct {
+	Name   string
+	Source string
+	Result string
+}
+
+// Benchmark_Result is how long one run of a script took on each engine.
+type Benchmark_Result struct {
+	Name   string
+	Walker time.Duration
+	VM     time.Duration
+}
+
+var Benchmark_Scripts = []Benchmark_Script{
+	{Name: "loop", Result: "199990000", Source: `var s = 0
+for i in 0..<20000 {
+	s = s + i
+}
+s`},
+	{Name: "calls", Result: "12497500", Source: `function add(a, b) {
+	return a + b
+}
+var s = 0
+for i in 0..<5000 {
+	s = add(s, i)
+}
+s`},
+	{Name: "array", Result: "500", Source: `var a = []
+for i in 0..<2000 {
+	a.push(i * 2)
+}
+a.map(function(x) { return x + 1 }).filter(function(x) { return x in 0..1000 }).length`},
+	{Name: "string", Result: "2000", Source: `var s = ""
+for c in range(2000) {
+	s = s + "x"
+}
+s.length`},
+	{Name: "map", Result: "1000", Source: `var m = Map()
+for i in 0..<2000 {
+	m.set(Math.floor(i / 2), i)
+}
+m.size`},
+	{Name: "destructure", Result: "3000", Source: `var s = 0
+for [k, v] in Object.entries(Object.from_entries([["a", 1], ["b", 2]])) {
+	s = s + v
+}
+var t = 0
+for i in 0..<1000 {
+	var [a, ...rest] = [s, 1, 2]
+	t = t + a
+}
+t`},
+	{Name: "generator", Result: "1999000", Source: `function* count(n) {
+	for i in 0..<n {
+		yield i
+	}
+}
+var s = 0
+for v in count(2000) {
+	s = s + v
+}
+s`},
+}
+
+func run_benchmark(nodes []Value, vm bool) (Value, any) {
+	inter := Interpreter{Use_VM: vm}
+	inter.Init()
+	locals := Create_Object(nil).(Object)
+	return inter.run(nodes, &locals)
+}
+
+// Run_Benchmarks checks that the tree walker and the VM return the
+// expected result for every script and then times both engines on it.
+func Run_Benchmarks(scripts []Benchmark_Script) ([]Benchmark_Result, error) {
+	re := []Benchmark_Result{}
+	for _, script := range scripts {
+		nodes, err := (&Parser{}).Parse(script.Source)
+		if err != nil {
+			return re, benchmark_error(script, err)
+		}
+		for _, vm := range []bool{false, true} {
+			v, err := run_benchmark(nodes, vm)
+			if err != nil {
+				return re, benchmark_error(script, err)
+			}
+			if v.Re_string("") != script.Result {
+				return re, errors.New(lang_text("erro msg16", []string{script.Name, v.Re_string(""), script.Result}))
+			}
+		}
+		re = append(re, Benchmark_Result{Name: script.Name, Walker: time_benchmark(nodes, false), VM: time_benchmark(nodes, true)})
+	}
+	return re, nil
+}
+
+// benchmark_time is how long time_benchmark runs a script for.
+const benchmark_time = 200 * time.Millisecond
+
+// time_benchmark runs nodes again and again for benchmark_time and
+// returns the mean time of a run.
+func time_benchmark(nodes []Value, vm bool) time.Duration {
+	n := 0
+	start := time.Now()
+	for n == 0 || time.Since(start) < benchmark_time {
+		run_benchmark(nodes, vm)
+		n++
+	}
+	return time.Since(start) / time.Duration(n)
+}
+func benchmark_error(script Benchmark_Script, err any) error {
+	msg := ""
+	if errs, ok := err.(Errors); ok {
+		for _, e := range errs {
+			e.lines = strings.Split(script.Source, "\n")
+			msg += conv_error_in_str(e) + "\n"
+		}
+		return errors.New(script.Name + ": " + msg)
+	}
+	for err != nil {
+		e, ok := err.(Error)
+		if !ok {
+			break
+		}
+		e.lines = strings.Split(script.Source, "\n")
+		msg += conv_error_in_str(e)
+		err = e.other_error
+	}
+	return errors.New(script.Name + ": " + msg)
+}
@@ -0,0 +1,448 @@
+package kll
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Tuple struct {
+	VTp   string `json:"value type"`
+	Value []Value
+}
+type Map struct {
+	VTp    string `json:"value type"`
+	keys   *OrderedMap
+	values *OrderedMap
+}
+type Set struct {
+	VTp    string `json:"value type"`
+	values *OrderedMap
+}
+
+func (this Tuple) On_sum(value Value) Value {
+	if value.VType() == "Tuple" {
+		return Create_Tuple(append(append([]Value{}, this.Value...), value.(Tuple).Value...))
+	}
+	return Create_Null()
+}
+func (this Tuple) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this Tuple) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this Tuple) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this Tuple) Re_string(prefix string) string {
+	str := "("
+	for i, e := range this.Value {
+		if i > 0 {
+			str += ", "
+		}
+		if e.VType() == "String" {
+			str += string('"') + e.Re_string("") + string('"')
+		} else {
+			str += e.Re_string("")
+		}
+	}
+	if len(this.Value) == 1 {
+		str += ","
+	}
+	return str + ")"
+}
+func (this Tuple) Re_number() float64 {
+	return -1
+}
+func (this Tuple) Re_bool() bool {
+	return len(this.Value) > 0
+}
+func (this Tuple) VType() string {
+	return "Tuple"
+}
+func (this Tuple) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	return Create_Null(), nil
+}
+func (this Tuple) On_get_attr(name string) Value {
+	switch name {
+	case "length":
+		return Create_Number(float64(len(this.Value)))
+	case "get":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			index, ok := get_arg(args, kwargs, 0, "index")
+			if !ok {
+				return Create_Null(), nil
+			}
+			i := int(index.Re_number())
+			if i < 0 {
+				i += len(this.Value)
+			}
+			if i < 0 || i >= len(this.Value) {
+				return Create_Null(), nil
+			}
+			return this.Value[i], nil
+		})
+	case "to_array":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_Array(append([]Value{}, this.Value...)), nil
+		})
+	}
+	return Create_Null()
+}
+func (this Tuple) Attr_Names() []string {
+	return []string{"length", "get", "to_array"}
+}
+func (this Tuple) On_set_attr(name string, value Value) Value {
+	return Create_Null()
+}
+func (this Tuple) On_in(name Value) Value {
+	for _, v := range this.Value {
+		if Equal(v, name) {
+			return Create_Bool(true)
+		}
+	}
+	return Create_Bool(false)
+}
+func (this Tuple) Re_hash() (string, bool) {
+	str := "t" + fmt.Sprint(len(this.Value)) + "("
+	for _, v := range this.Value {
+		h, ok := v.Re_hash()
+		if !ok {
+			return "", false
+		}
+		str += h + ";"
+	}
+	return str + ")", true
+}
+
+func (this Map) On_sum(value Value) Value {
+	return Create_Null()
+}
+func (this Map) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this Map) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this Map) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this Map) Re_string(prefix string) string {
+	strs := []string{}
+	for _, h := range this.keys.Keys() {
+		k, _ := this.keys.Get(h)
+		v, _ := this.values.Get(h)
+		strs = append(strs, quote_value(k.Value)+" => "+quote_value(v.Value))
+	}
+	return "Map {" + strings.Join(strs, ", ") + "}"
+}
+func (this Map) Re_number() float64 {
+	return -1
+}
+func (this Map) Re_bool() bool {
+	return this.keys.Len() > 0
+}
+func (this Map) VType() string {
+	return "Map"
+}
+func (this Map) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	return Create_Null(), nil
+}
+func (this Map) On_get_attr(name string) Value {
+	switch name {
+	case "size":
+		return Create_Number(float64(this.keys.Len()))
+	case "get":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			h, err := get_hash(args, kwargs, 0, "key")
+			if err != nil {
+				return Create_Null(), err
+			}
+			if v, ok := this.values.Get(h); ok {
+				return v.Value, nil
+			}
+			if def, ok := get_arg(args, kwargs, 1, "default"); ok {
+				return def, nil
+			}
+			return Create_Null(), nil
+		})
+	case "set":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			h, err := get_hash(args, kwargs, 0, "key")
+			if err != nil {
+				return Create_Null(), err
+			}
+			key, _ := get_arg(args, kwargs, 0, "key")
+			value, ok := get_arg(args, kwargs, 1, "value")
+			if !ok {
+				value = Create_Null()
+			}
+			this.set(h, key, value)
+			return this, nil
+		})
+	case "has":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			key, _ := get_arg(args, kwargs, 0, "key")
+			if key == nil {
+				return Create_Bool(false), nil
+			}
+			return this.On_in(key), nil
+		})
+	case "delete":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			h, err := get_hash(args, kwargs, 0, "key")
+			if err != nil {
+				return Create_Null(), err
+			}
+			this.values.Delete(h)
+			return Create_Bool(this.keys.Delete(h)), nil
+		})
+	case "clear":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			*this.keys = *Create_OrderedMap()
+			*this.values = *Create_OrderedMap()
+			return Create_Null(), nil
+		})
+	case "keys":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_Array(ordered_values(this.keys)), nil
+		})
+	case "values":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_Array(ordered_values(this.values)), nil
+		})
+	case "entries":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_Array(this.entries()), nil
+		})
+	case "for_each":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			function, err := get_callback(args, kwargs, 0, "function")
+			if err != nil {
+				return Create_Null(), err
+			}
+			for _, e := range this.entries() {
+				pair := e.(Array).Items()
+				_, err := Call(function, []Value{pair[1], pair[0]}, map[string]*Variable{}, pos)
+				if err != nil {
+					return Create_Null(), err
+				}
+			}
+			return Create_Null(), nil
+		})
+	}
+	return Create_Null()
+}
+func (this Map) Attr_Names() []string {
+	return []string{"size", "get", "set", "has", "delete", "clear", "keys", "values", "entries", "for_each"}
+}
+func (this Map) On_set_attr(name string, value Value) Value {
+	return Create_Null()
+}
+func (this Map) On_in(name Value) Value {
+	h, ok := name.Re_hash()
+	return Create_Bool(ok && this.keys.Has(h))
+}
+func (this Map) Re_hash() (string, bool) {
+	return "", false
+}
+func (this Map) set(h string, key Value, value Value) {
+	this.keys.Set(h, Variable{Value: key})
+	this.values.Set(h, Variable{Value: value})
+}
+func (this Map) entries() []Value {
+	re := []Value{}
+	for _, h := range this.keys.Keys() {
+		k, _ := this.keys.Get(h)
+		v, _ := this.values.Get(h)
+		re = append(re, Create_Array([]Value{k.Value, v.Value}))
+	}
+	return re
+}
+
+func (this Set) On_sum(value Value) Value {
+	return Create_Null()
+}
+func (this Set) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this Set) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this Set) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this Set) Re_string(prefix string) string {
+	strs := []string{}
+	for _, v := range ordered_values(this.values) {
+		strs = append(strs, quote_value(v))
+	}
+	return "Set {" + strings.Join(strs, ", ") + "}"
+}
+func (this Set) Re_number() float64 {
+	return -1
+}
+func (this Set) Re_bool() bool {
+	return this.values.Len() > 0
+}
+func (this Set) VType() string {
+	return "Set"
+}
+func (this Set) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	return Create_Null(), nil
+}
+func (this Set) On_get_attr(name string) Value {
+	switch name {
+	case "size":
+		return Create_Number(float64(this.values.Len()))
+	case "add":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			h, err := get_hash(args, kwargs, 0, "value")
+			if err != nil {
+				return Create_Null(), err
+			}
+			value, _ := get_arg(args, kwargs, 0, "value")
+			this.values.Set(h, Variable{Value: value})
+			return this, nil
+		})
+	case "has":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			value, _ := get_arg(args, kwargs, 0, "value")
+			if value == nil {
+				return Create_Bool(false), nil
+			}
+			return this.On_in(value), nil
+		})
+	case "delete":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			h, err := get_hash(args, kwargs, 0, "value")
+			if err != nil {
+				return Create_Null(), err
+			}
+			return Create_Bool(this.values.Delete(h)), nil
+		})
+	case "clear":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			*this.values = *Create_OrderedMap()
+			return Create_Null(), nil
+		})
+	case "values":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_Array(ordered_values(this.values)), nil
+		})
+	case "for_each":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			function, err := get_callback(args, kwargs, 0, "function")
+			if err != nil {
+				return Create_Null(), err
+			}
+			for _, v := range ordered_values(this.values) {
+				_, err := Call(function, []Value{v}, map[string]*Variable{}, pos)
+				if err != nil {
+					return Create_Null(), err
+				}
+			}
+			return Create_Null(), nil
+		})
+	}
+	return Create_Null()
+}
+func (this Set) Attr_Names() []string {
+	return []string{"size", "add", "has", "delete", "clear", "values", "for_each"}
+}
+func (this Set) On_set_attr(name string, value Value) Value {
+	return Create_Null()
+}
+func (this Set) On_in(name Value) Value {
+	h, ok := name.Re_hash()
+	return Create_Bool(ok && this.values.Has(h))
+}
+func (this Set) Re_hash() (string, bool) {
+	return "", false
+}
+
+func quote_value(value Value) string {
+	if value.VType() == "String" {
+		return string('"') + value.Re_string("") + string('"')
+	}
+	return value.Re_string("")
+}
+func ordered_values(values *OrderedMap) []Value {
+	re := []Value{}
+	values.Each(func(key string, value Variable) bool {
+		re = append(re, value.Value)
+		return true
+	})
+	return re
+}
+func get_hash(args []Value, kwargs map[string]*Variable, index int, name string) (string, any) {
+	v, ok := get_arg(args, kwargs, index, name)
+	if !ok {
+		v = Create_Null()
+	}
+	h, ok := Hash(v)
+	if !ok {
+		return "", Error{code: Code_Not_Hashable, kind: "erro3", key: "erro msg12", args: []string{v.Re_string("")}}
+	}
+	return h, nil
+}
+
+func Create_Tuple(values []Value) Value {
+	re := Tuple{Value: values}
+	re.VTp = re.VType()
+	return re
+}
+func Create_Map() Map {
+	re := Map{keys: Create_OrderedMap(), values: Create_OrderedMap()}
+	re.VTp = re.VType()
+	return re
+}
+func Create_Set() Set {
+	re := Set{values: Create_OrderedMap()}
+	re.VTp = re.VType()
+	return re
+}
+
+// New_Map builds a Map from an optional list of [key, value] pairs.
+func New_Map(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	re := Create_Map()
+	entries, ok := get_arg(args, kwargs, 0, "entries")
+	if !ok || entries.VType() != "Array" {
+		return re, nil
+	}
+	for _, e := range entries.(Array).Items() {
+		var pair []Value
+		switch e.VType() {
+		case "Array":
+			pair = e.(Array).Items()
+		case "Tuple":
+			pair = e.(Tuple).Value
+		}
+		if len(pair) < 2 {
+			continue
+		}
+		h, err := get_hash(pair, nil, 0, "")
+		if err != nil {
+			return Create_Null(), err
+		}
+		re.set(h, pair[0], pair[1])
+	}
+	return re, nil
+}
+
+// New_Set builds a Set from an optional Array of values.
+func New_Set(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	re := Create_Set()
+	values, ok := get_arg(args, kwargs, 0, "values")
+	if !ok || values.VType() != "Array" {
+		return re, nil
+	}
+	for _, v := range values.(Array).Items() {
+		h, err := get_hash([]Value{v}, nil, 0, "")
+		if err != nil {
+			return Create_Null(), err
+		}
+		re.values.Set(h, Variable{Value: v})
+	}
+	return re, nil
+}
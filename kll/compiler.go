@@ -0,0 +1,502 @@
+package kll
+
+import (
+	"fmt"
+	"strings"
+)
+
+type opcode uint8
+
+const (
+	op_const opcode = iota
+	op_null
+	op_pop
+	op_dup
+	op_load_slot
+	op_store_slot
+	op_def_slot
+	op_load_name
+	op_load_name_mut
+	op_store_name
+	op_def_name
+	op_def_global
+	op_exist
+	op_get_attr
+	op_get_method
+	op_set_attr
+	op_add
+	op_sub
+	op_mul
+	op_div
+	op_eq
+	op_in
+	op_and
+	op_or
+	op_neg
+	op_range
+	op_array
+	op_list_new
+	op_list_push
+	op_list_extend
+	op_call
+	op_call_list
+	op_jump
+	op_jump_if_false
+	op_iter
+	op_for_next
+	op_enter
+	op_leave
+	op_unpack_next
+	op_unpack_rest
+	op_iter_end
+	op_closure
+	op_return
+	op_yield
+	op_pointer
+	op_fail
+)
+
+var opcode_names = [...]string{
+	"CONST", "NULL", "POP", "DUP", "LOAD_SLOT", "STORE_SLOT", "DEF_SLOT", "LOAD_NAME", "LOAD_NAME_MUT",
+	"STORE_NAME", "DEF_NAME", "DEF_GLOBAL", "EXIST", "GET_ATTR", "GET_METHOD", "SET_ATTR", "ADD",
+	"SUB", "MUL", "DIV", "EQ", "IN", "AND", "OR", "NEG", "RANGE", "ARRAY", "LIST_NEW", "LIST_PUSH",
+	"LIST_EXTEND", "CALL", "CALL_LIST", "JUMP", "JUMP_IF_FALSE", "ITER", "FOR_NEXT",
+	"ENTER", "LEAVE", "UNPACK_NEXT", "UNPACK_REST", "ITER_END", "CLOSURE", "RETURN", "YIELD",
+	"POINTER", "FAIL",
+}
+
+var binary_opcodes = map[string]opcode{"+": op_add, "-": op_sub, "*": op_mul, "/": op_div, "==": op_eq, "&&": op_and, "||": op_or}
+
+type instruction struct {
+	op   opcode
+	a    int32
+	b    int32
+	line uint32
+	col  uint32
+}
+
+// Bytecode is a compiled module or function body. Names and literals live
+// in consts, and nested functions in protos. Variables use the slots given
+// by Resolve; the ones it leaves to be looked up by name, like the globals,
+// are found in locals and Globals.
+type Bytecode struct {
+	name      string
+	code      []instruction
+	consts    []Value
+	protos    []*Bytecode
+	errors    []any
+	params    []string
+	slots     int
+	generator bool
+	// calls holds the callee node of each call instruction, at the index
+	// of the instruction, to name the functions of tracebacks.
+	calls []Value
+}
+
+type compiler struct {
+	code  *Bytecode
+	names map[string]int
+}
+
+// Compile turns the nodes returned by Parser.Parse into Bytecode for the
+// VM, resolving them first.
+func Compile(nodes []Value) *Bytecode {
+	slots, _ := Resolve(nodes, nil)
+	code := compile_resolved(nodes)
+	code.slots = slots
+	return code
+}
+func compile_resolved(nodes []Value) *Bytecode {
+	c := &compiler{code: &Bytecode{name: "<module>"}, names: map[string]int{}}
+	if len(nodes) == 0 {
+		c.emit(op_null, 0, 0, Node{})
+	}
+	for i, n := range nodes {
+		c.compile(n)
+		if i < len(nodes)-1 {
+			c.discard(n.(Node))
+		}
+	}
+	c.emit(op_return, 0, 0, Node{})
+	return c.code
+}
+
+func (this *compiler) emit(op opcode, a int, b int, node Node) int {
+	this.code.code = append(this.code.code, instruction{op: op, a: int32(a), b: int32(b), line: uint32(node.Line), col: uint32(node.Col)})
+	return len(this.code.code) - 1
+}
+func (this *compiler) patch(at int) {
+	this.code.code[at].a = int32(len(this.code.code))
+}
+func (this *compiler) constant(value Value) int {
+	this.code.consts = append(this.code.consts, value)
+	return len(this.code.consts) - 1
+}
+func (this *compiler) name(name string) int {
+	if i, ok := this.names[name]; ok {
+		return i
+	}
+	this.names[name] = this.constant(Create_String(name))
+	return this.names[name]
+}
+func (this *compiler) block(nodes []Value) {
+	for _, n := range nodes {
+		this.compile(n)
+		this.discard(n.(Node))
+	}
+}
+
+// discard drops the value a statement leaves on the stack. Instead of a
+// POP, the NULL of an if, a for or a declaration is removed, as is the DUP
+// keeping the value of an assignment. Jumps only land on loops and after
+// blocks, never on the instructions removed, and the ones landing after
+// them land on what comes next.
+func (this *compiler) discard(node Node) {
+	code := this.code.code
+	n := len(code)
+	if n > 0 && code[n-1].op == op_null {
+		this.code.code = code[:n-1]
+		return
+	}
+	if n > 1 && code[n-2].op == op_dup {
+		switch code[n-1].op {
+		case op_store_slot, op_store_name, op_def_slot, op_def_name, op_def_global:
+			code[n-2] = code[n-1]
+			this.code.code = code[:n-1]
+			return
+		}
+	}
+	this.emit(op_pop, 0, 0, node)
+}
+
+// define pops the value on top of the stack into the variable declared by
+// the "var" node target.
+func (this *compiler) define(target Node, global bool) {
+	name := target.Value[0].Re_string("")
+	if global {
+		this.emit(op_def_global, this.name(name), 0, target)
+	} else if _, slot, ok := var_slot(target); ok {
+		this.emit(op_def_slot, slot, 0, target)
+	} else {
+		this.emit(op_def_name, this.name(name), 0, target)
+	}
+}
+func (this *compiler) load(node Node, mutable bool) {
+	if depth, slot, ok := var_slot(node); ok {
+		this.emit(op_load_slot, slot, depth, node)
+	} else if mutable {
+		this.emit(op_load_name_mut, this.name(node.Value[0].Re_string("")), this.hints(node), node)
+	} else {
+		this.emit(op_load_name, this.name(node.Value[0].Re_string("")), this.hints(node), node)
+	}
+}
+
+// hints is the constant, plus one, of the names var_hints returns for
+// node, or 0 when there are none.
+func (this *compiler) hints(node Node) int {
+	if len(node.Value) != 2 {
+		return 0
+	}
+	return this.constant(node.Value[1]) + 1
+}
+func (this *compiler) compile(nodeV Value) {
+	node := nodeV.(Node)
+	switch node.Tp {
+	case "value":
+		this.emit(op_const, this.constant(node.Value[0]), 0, node)
+	case "null":
+		this.emit(op_null, 0, 0, node)
+	case "()":
+		this.compile(node.Value[0])
+	case "var":
+		this.load(node, false)
+	case "array":
+		spread := false
+		for _, v := range node.Value {
+			spread = spread || v.(Node).Tp == "spread"
+		}
+		if !spread {
+			for _, v := range node.Value {
+				this.compile(v)
+			}
+			this.emit(op_array, len(node.Value), 0, node)
+			break
+		}
+		this.emit(op_list_new, 0, 0, node)
+		this.list_items(node.Value)
+	case "get attr":
+		this.compile(node.Value[0])
+		for _, name := range attr_path(node.Value[1]) {
+			this.emit(op_get_attr, this.name(name), 1, node)
+		}
+	case "function", "generator":
+		name := node.Value[0].Re_string("")
+		this.emit(op_closure, this.function_proto(node), 0, node)
+		if _, slot := function_slots(node); slot >= 0 {
+			this.emit(op_dup, 0, 0, node)
+			this.emit(op_def_slot, slot, 0, node)
+		} else if name != "" {
+			this.emit(op_dup, 0, 0, node)
+			this.emit(op_def_name, this.name(name), 0, node)
+		}
+	case "exist":
+		if _, _, ok := var_slot(node.Value[0].(Node)); ok {
+			this.emit(op_const, this.constant(Create_Bool(true)), 0, node)
+		} else {
+			this.emit(op_exist, this.name(node.Value[0].(Node).Value[0].Re_string("")), 0, node)
+		}
+	case "call":
+		this.call(node)
+	case "create global", "create local":
+		global := node.Tp == "create global"
+		target := node.Value[0].(Node)
+		if target.Tp == "var" {
+			this.emit(op_null, 0, 0, node)
+			this.define(target, global)
+			this.emit(op_null, 0, 0, node)
+		} else if target.Tp == "=" {
+			this.compile(target.Value[1])
+			this.emit(op_dup, 0, 0, node)
+			this.destructure(target.Value[0].(Node), global)
+		} else {
+			this.emit(op_null, 0, 0, node)
+		}
+	case "=":
+		this.assign(node)
+	case "inverse number":
+		this.compile(node.Value[0])
+		this.emit(op_neg, 0, 0, node)
+	case "+", "-", "*", "/", "==", "&&", "||":
+		this.compile(node.Value[0])
+		this.compile(node.Value[1])
+		this.emit(binary_opcodes[node.Tp], 0, 0, node)
+	case "in":
+		this.compile(node.Value[0])
+		this.compile(node.Value[1])
+		this.emit(op_in, 0, 0, node)
+	case "range":
+		this.compile(node.Value[0])
+		this.compile(node.Value[1])
+		inclusive := 0
+		if node.Value[2].Re_bool() {
+			inclusive = 1
+		}
+		this.emit(op_range, inclusive, 0, node)
+	case "if":
+		this.compile(node.Value[0])
+		jump := this.emit(op_jump_if_false, 0, 0, node)
+		this.block(node.Value[1].(Node).Value)
+		this.patch(jump)
+		this.emit(op_null, 0, 0, node)
+	case "for":
+		this.compile(node.Value[1])
+		this.emit(op_iter, 0, 0, node)
+		loop := len(this.code.code)
+		next := this.emit(op_for_next, 0, 0, node)
+		slots, own := loop_slots(node)
+		if own {
+			this.emit(op_enter, slots, 0, node)
+		}
+		this.destructure(node.Value[0].(Node), false)
+		this.block(node.Value[2].(Node).Value)
+		if own {
+			this.emit(op_leave, 0, 0, node)
+		}
+		this.emit(op_jump, loop, 0, node)
+		this.patch(next)
+		this.emit(op_null, 0, 0, node)
+	case "return":
+		this.compile(node.Value[0])
+		this.emit(op_return, 0, 0, node)
+	case "yield":
+		this.compile(node.Value[0])
+		this.emit(op_yield, 0, 0, node)
+	case "pointer":
+		if target := node.Value[0].(Node); target.Tp == "var" {
+			this.emit(op_pointer, this.name(target.Value[0].Re_string("")), 0, node)
+		} else {
+			this.emit(op_null, 0, 0, node)
+		}
+	case "spread":
+		this.fail(Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: node.Line, col: node.Col}, node)
+	default:
+		this.emit(op_null, 0, 0, node)
+	}
+}
+
+// fail compiles an instruction raising err.
+func (this *compiler) fail(err Error, node Node) {
+	this.code.errors = append(this.code.errors, err)
+	this.emit(op_fail, len(this.code.errors)-1, 0, node)
+}
+
+// list_items appends values to the Array on top of the stack, spreading
+// the "spread" nodes into it.
+func (this *compiler) list_items(values []Value) {
+	for _, v := range values {
+		if v.(Node).Tp == "spread" {
+			this.compile(v.(Node).Value[0])
+			this.emit(op_list_extend, 0, 0, v.(Node))
+		} else {
+			this.compile(v)
+			this.emit(op_list_push, 0, 0, v.(Node))
+		}
+	}
+}
+
+// call leaves the positional arguments, the keyword argument values and
+// the callee on the stack, in that order, like the tree walker evaluates
+// them.
+func (this *compiler) call(node Node) {
+	params := node.Value[1].(Node).Value
+	positional := []Value{}
+	spread := false
+	for _, p := range params {
+		if p.(Node).Tp != "=" {
+			positional = append(positional, p)
+			spread = spread || p.(Node).Tp == "spread"
+		}
+	}
+	if spread {
+		this.emit(op_list_new, 0, 0, node)
+		this.list_items(positional)
+	} else {
+		for _, p := range positional {
+			this.compile(p)
+		}
+	}
+	names := []Value{}
+	for _, p := range params {
+		if p.(Node).Tp == "=" {
+			names = append(names, Create_String(p.(Node).Value[0].(Node).Value[0].Re_string("")))
+			this.compile(p.(Node).Value[1])
+		}
+	}
+	kw := -1
+	if len(names) > 0 {
+		kw = this.constant(Create_Tuple(names))
+	}
+	callee := node.Value[0].(Node)
+	if callee.Tp == "get attr" && len(attr_path(callee.Value[1])) > 0 {
+		path := attr_path(callee.Value[1])
+		this.compile(callee.Value[0])
+		for _, name := range path[:len(path)-1] {
+			this.emit(op_get_attr, this.name(name), 1, callee)
+		}
+		this.emit(op_get_method, this.name(path[len(path)-1]), 0, callee)
+	} else {
+		this.compile(callee)
+	}
+	at := 0
+	if spread {
+		at = this.emit(op_call_list, 0, kw, callee)
+	} else {
+		at = this.emit(op_call, len(positional), kw, callee)
+	}
+	for len(this.code.calls) < at {
+		this.code.calls = append(this.code.calls, nil)
+	}
+	this.code.calls = append(this.code.calls, callee)
+}
+
+// assign compiles "=", which leaves the assigned value on the stack.
+func (this *compiler) assign(node Node) {
+	target := node.Value[0].(Node)
+	this.compile(node.Value[1])
+	this.emit(op_dup, 0, 0, node)
+	if target.Tp != "get attr" {
+		if depth, slot, ok := var_slot(target); ok {
+			this.emit(op_store_slot, slot, depth, node)
+		} else {
+			this.emit(op_store_name, this.name(target.Value[0].Re_string("")), this.hints(target), node)
+		}
+		return
+	}
+	this.load(target.Value[0].(Node), true)
+	path := attr_path(target.Value[1])
+	if len(path) == 0 {
+		this.emit(op_pop, 0, 0, node)
+		this.emit(op_pop, 0, 0, node)
+		return
+	}
+	for _, name := range path[:len(path)-1] {
+		this.emit(op_get_attr, this.name(name), 0, node)
+	}
+	this.emit(op_set_attr, this.name(path[len(path)-1]), 0, node)
+}
+
+// destructure pops the value on top of the stack into target, like
+// Interpreter.destructure.
+func (this *compiler) destructure(target Node, global bool) {
+	switch target.Tp {
+	case "var":
+		this.define(target, global)
+	case "array":
+		this.emit(op_iter, 0, 0, target)
+		for _, t := range target.Value {
+			if t.(Node).Tp == "spread" {
+				this.emit(op_unpack_rest, 0, 0, t.(Node))
+				this.destructure(t.(Node).Value[0].(Node), global)
+				break
+			}
+			this.emit(op_unpack_next, 0, 0, t.(Node))
+			this.destructure(t.(Node), global)
+		}
+		this.emit(op_iter_end, 0, 0, target)
+	default:
+		this.fail(Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: target.Line, col: target.Col}, target)
+	}
+}
+func (this *compiler) function_proto(node Node) int {
+	proto := &Bytecode{name: node.Value[0].Re_string(""), generator: node.Tp == "generator"}
+	proto.slots, _ = function_slots(node)
+	c := &compiler{code: proto, names: map[string]int{}}
+	for _, v := range node.Value[1].(Node).Value {
+		if v.(Node).Tp == "var" {
+			proto.params = append(proto.params, v.(Node).Value[0].Re_string(""))
+		}
+	}
+	c.block(node.Value[2].(Node).Value)
+	c.emit(op_null, 0, 0, node)
+	c.emit(op_return, 0, 0, node)
+	this.code.protos = append(this.code.protos, proto)
+	return len(this.code.protos) - 1
+}
+
+// attr_path flattens the name side of a "get attr" node, a.b.c being
+// parsed as a with the path b.c.
+func attr_path(name Value) []string {
+	node, ok := name.(Node)
+	if !ok {
+		return nil
+	}
+	switch node.Tp {
+	case "var":
+		return []string{node.Value[0].Re_string("")}
+	case "get attr":
+		return append(attr_path(node.Value[0]), attr_path(node.Value[1])...)
+	}
+	return nil
+}
+
+// Disassemble lists the instructions of the bytecode and of the functions
+// it declares, for debugging.
+func (this *Bytecode) Disassemble() string {
+	var b strings.Builder
+	this.disassemble(&b, "")
+	return b.String()
+}
+func (this *Bytecode) disassemble(b *strings.Builder, prefix string) {
+	fmt.Fprintf(b, "%s%s (params: %v, slots: %d)\n", prefix, this.name, this.params, this.slots)
+	for i, in := range this.code {
+		fmt.Fprintf(b, "%s%4d %-14s %d %d", prefix, i, opcode_names[in.op], in.a, in.b)
+		switch in.op {
+		case op_const, op_load_name, op_load_name_mut, op_store_name, op_def_name, op_def_global, op_exist, op_get_attr, op_get_method, op_set_attr, op_pointer:
+			fmt.Fprintf(b, "\t; %s", this.consts[in.a].Re_string(""))
+		}
+		b.WriteString("\n")
+	}
+	for _, p := range this.protos {
+		p.disassemble(b, prefix+"  ")
+	}
+}
@@ -0,0 +1,242 @@
+package kll
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Code identifies the kind of an Error. Codes don't change between
+// versions, and a Code is itself an error, so it can be matched with
+// errors.Is(err, Code_Undefined_Variable) anywhere in the chain.
+type Code string
+
+func (this Code) Error() string {
+	return string(this)
+}
+
+const (
+	Code_Unterminated_String     Code = "KLL1001"
+	Code_Unknown_Symbol          Code = "KLL1002"
+	Code_Invalid_Number          Code = "KLL1003"
+	Code_Unexpected_Token        Code = "KLL1004"
+	Code_Invalid_Expression      Code = "KLL1005"
+	Code_Unclosed_Brace          Code = "KLL1006"
+	Code_Unclosed_Paren          Code = "KLL1007"
+	Code_Unclosed_Bracket        Code = "KLL1008"
+	Code_Yield_Outside_Generator Code = "KLL1009"
+	Code_Undefined_Variable      Code = "KLL2001"
+	Code_Not_Callable            Code = "KLL3001"
+	Code_Not_Object              Code = "KLL3002"
+	Code_Not_Hashable            Code = "KLL3003"
+	Code_Not_Iterable            Code = "KLL3004"
+	Code_Zero_Step               Code = "KLL3005"
+	Code_Missing_Attribute       Code = "KLL3006"
+	Code_Invalid_Exit_Code       Code = "KLL3007"
+	Code_Assertion_Failed        Code = "KLL4001"
+	Code_Unused_Variable         Code = "KLL5001"
+	Code_Shadowed_Variable       Code = "KLL5002"
+	Code_Const_Assignment        Code = "KLL5003"
+	Code_Unreachable_Code        Code = "KLL5004"
+	Code_Not_Callable_Literal    Code = "KLL5005"
+	Code_Always_False            Code = "KLL5006"
+	Code_Implicit_Global         Code = "KLL5007"
+)
+
+type Severity string
+
+const (
+	Severity_Error   Severity = "error"
+	Severity_Warning Severity = "warning"
+)
+
+// Position is a line and a column, both starting at 1.
+type Position struct {
+	Line uint64 `json:"line"`
+	Col  uint64 `json:"col"`
+}
+
+// Span is the text an Error points at. End is exclusive.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func (this Error) Error() string {
+	re := this.message()
+	if this.line != 0 {
+		re += " line:" + fmt.Sprint(this.line) + ",collum:" + fmt.Sprint(this.col)
+	}
+	if this.other_error != nil {
+		re += ": " + fmt.Sprint(this.other_error)
+	}
+	return strings.TrimSpace(re)
+}
+
+// Message is the text of the error in the locale of the Interpreter or
+// Parser that returned it.
+func (this Error) Message() string {
+	return this.message()
+}
+func (this Error) message() string {
+	if this.key == "" {
+		return ""
+	}
+	return lang_text_in(this.locale, this.kind, nil) + lang_text_in(this.locale, this.key, this.args)
+}
+
+// localize sets the locale of err and of the errors of its chain.
+func localize(err any, locale string) any {
+	switch e := err.(type) {
+	case Error:
+		e.locale = locale
+		e.other_error = localize(e.other_error, locale)
+		return e
+	case Errors:
+		re := make(Errors, len(e))
+		for i, v := range e {
+			re[i] = localize(v, locale).(Error)
+		}
+		return re
+	}
+	return err
+}
+
+// Code is empty for the errors that only add the position of a call to
+// the error of the called function, see Cause.
+func (this Error) Code() Code {
+	return this.code
+}
+func (this Error) Severity() Severity {
+	if this.severity == "" {
+		return Severity_Error
+	}
+	return this.severity
+}
+func (this Error) Line() uint64 {
+	return this.line
+}
+func (this Error) Col() uint64 {
+	return this.col
+}
+func (this Error) Span() Span {
+	re := Span{Start: Position{Line: this.line, Col: this.col}, End: Position{Line: this.end_line, Col: this.end_col}}
+	if re.End.Line == 0 {
+		re.End = re.Start
+	}
+	return re
+}
+
+// Cause is the error this one was raised from, an Error or a Go error
+// returned by a GoFunction, or nil.
+func (this Error) Cause() any {
+	return this.other_error
+}
+func (this Error) Unwrap() error {
+	err, _ := this.other_error.(error)
+	return err
+}
+func (this Error) Is(target error) bool {
+	code, ok := target.(Code)
+	return ok && code != "" && code == this.code
+}
+
+func (this Errors) Error() string {
+	re := []string{}
+	for _, e := range this {
+		re = append(re, e.Error())
+	}
+	return strings.Join(re, "\n")
+}
+
+// Is matches the Code of any of the errors.
+func (this Errors) Is(target error) bool {
+	for _, e := range this {
+		if e.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// to_error turns the errors of the Interpreter, which can be any value a
+// GoFunction returned, into an error. No syntax errors is nil.
+func to_error(err any) error {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case Errors:
+		if len(e) == 0 {
+			return nil
+		}
+		return e
+	case error:
+		return e
+	}
+	return fmt.Errorf("%v", err)
+}
+
+// Exit is the error process.exit stops the script with. The Interpreter
+// doesn't end the process itself; the embedder decides what to do with
+// Code.
+type Exit struct {
+	Code int
+}
+
+func (this Exit) Error() string {
+	return "exit status " + fmt.Sprint(this.Code)
+}
+
+// Diagnostic is the form of an Error written by Encode_Diagnostics.
+type Diagnostic struct {
+	Code      Code         `json:"code,omitempty"`
+	Severity  Severity     `json:"severity"`
+	Message   string       `json:"message"`
+	Span      Span         `json:"span"`
+	Cause     *Diagnostic  `json:"cause,omitempty"`
+	Traceback []Call_Frame `json:"traceback,omitempty"`
+}
+
+func (this Error) Diagnostic() Diagnostic {
+	re := Diagnostic{Code: this.code, Severity: this.Severity(), Message: this.message(), Span: this.Span(), Traceback: this.traceback}
+	if this.other_error != nil {
+		cause := diagnostic(this.other_error)
+		re.Cause = &cause
+	}
+	return re
+}
+func diagnostic(err any) Diagnostic {
+	switch e := err.(type) {
+	case Error:
+		return e.Diagnostic()
+	case error:
+		return Diagnostic{Severity: Severity_Error, Message: e.Error()}
+	}
+	return Diagnostic{Severity: Severity_Error, Message: fmt.Sprint(err)}
+}
+
+// Diagnostics lists the errors returned by Parser.Parse, the Interpreter or
+// the resolver, one per syntax error.
+func Diagnostics(err any) []Diagnostic {
+	re := []Diagnostic{}
+	switch e := err.(type) {
+	case nil:
+	case Errors:
+		for _, v := range e {
+			re = append(re, v.Diagnostic())
+		}
+	case []any:
+		for _, v := range e {
+			re = append(re, diagnostic(v))
+		}
+	default:
+		re = append(re, diagnostic(e))
+	}
+	return re
+}
+
+// Encode_Diagnostics writes Diagnostics(err) to w as a JSON array.
+func Encode_Diagnostics(w io.Writer, err any) error {
+	return json.NewEncoder(w).Encode(Diagnostics(err))
+}
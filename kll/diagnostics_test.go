@@ -0,0 +1,106 @@
+package kll
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEncodeDiagnostics(t *testing.T) {
+	_, err := (&Parser{Locale: "en"}).Parse("var a = )\nvar b = 1 +")
+	var b bytes.Buffer
+	if err := Encode_Diagnostics(&b, err); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"code":"KLL1004","severity":"error","message":"Syntax Error: this is in the wrong place","span":{"start":{"line":1,"col":9},"end":{"line":1,"col":10}}},` +
+		`{"code":"KLL1005","severity":"error","message":"Syntax Error: invalid expression","span":{"start":{"line":2,"col":11},"end":{"line":2,"col":11}}}]` + "\n"
+	if b.String() != want {
+		t.Errorf("got  %s\nwant %s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := Encode_Diagnostics(&b, nil); err != nil || b.String() != "[]\n" {
+		t.Errorf("no error: got %q, %v, want []", b.String(), err)
+	}
+}
+
+// TestEncodeRuntimeDiagnostics checks the chain of causes of an error
+// raised inside a call, down to the error with the code and traceback.
+func TestEncodeRuntimeDiagnostics(t *testing.T) {
+	inter := Interpreter{Locale: "en"}
+	inter.Init()
+	locals := Create_Object(nil).(Object)
+	_, err := inter.Eval("function f() {\n\t[1].map(2)\n}\nf()", &locals)
+	var b bytes.Buffer
+	if err := Encode_Diagnostics(&b, err); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(decoded))
+	}
+	type position struct{ line, col float64 }
+	start := func(d map[string]any) position {
+		s := d["span"].(map[string]any)["start"].(map[string]any)
+		return position{s["line"].(float64), s["col"].(float64)}
+	}
+	d := decoded[0]
+	for _, want := range []position{{4, 1}, {2, 5}} {
+		if d["severity"] != "error" || start(d) != want {
+			t.Fatalf("got %v, want a call at %v", d, want)
+		}
+		d = d["cause"].(map[string]any)
+	}
+	if d["code"] != string(Code_Not_Callable) || d["message"] != "Type Error: the value '2' is not a function" {
+		t.Errorf("the innermost cause is %v", d)
+	}
+	functions := []string{}
+	for _, frame := range d["traceback"].([]any) {
+		functions = append(functions, frame.(map[string]any)["function"].(string))
+	}
+	if fmt.Sprint(functions) != "[<module> f map]" {
+		t.Errorf("traceback %v, want [<module> f map]", functions)
+	}
+}
+
+func TestErrorsIs(t *testing.T) {
+	_, err := (&Parser{}).Parse("var a = )\nvar b = 1 +")
+	syntax := to_error(err)
+	inter := Interpreter{}
+	inter.Init()
+	locals := Create_Object(nil).(Object)
+	_, runtime := inter.Eval("function f() {\n\t[1].map(2)\n}\nf()", &locals)
+	_, undefined := inter.Eval("nada", &locals)
+	tests := []struct {
+		name string
+		err  error
+		is   []Code
+		not  []Code
+	}{
+		{name: "syntax errors", err: syntax, is: []Code{Code_Unexpected_Token, Code_Invalid_Expression}, not: []Code{Code_Unclosed_Paren}},
+		{name: "inside calls", err: runtime, is: []Code{Code_Not_Callable}, not: []Code{Code_Not_Object, ""}},
+		{name: "before running", err: undefined, is: []Code{Code_Undefined_Variable}, not: []Code{Code_Not_Callable}},
+		{name: "a code", err: Code_Zero_Step, is: []Code{Code_Zero_Step}, not: []Code{Code_Not_Iterable}},
+	}
+	for _, test := range tests {
+		for _, code := range test.is {
+			if !errors.Is(test.err, code) {
+				t.Errorf("%s: errors.Is(err, %s) is false", test.name, code)
+			}
+		}
+		for _, code := range test.not {
+			if errors.Is(test.err, code) {
+				t.Errorf("%s: errors.Is(err, %q) is true", test.name, code)
+			}
+		}
+	}
+	var e Error
+	if !errors.As(runtime, &e) || e.Line() != 4 {
+		t.Errorf("errors.As gave %v at line %d, want the call at line 4", e, e.Line())
+	}
+}
@@ -0,0 +1,430 @@
+// Package format prints kll scripts in one style: four spaces of
+// indentation, one statement per line, spaces around binary operators and
+// the canonical spelling of keywords (var, &&, ||). Comments and single
+// blank lines between statements are kept.
+package format
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/kaklikOf13/kll"
+	"github.com/kaklikOf13/kll/ast"
+)
+
+const indent = "    "
+
+// Err_Changed is returned when the formatted script would not parse into
+// the same tree as the original, so formatting it would change what it
+// does.
+var Err_Changed = errors.New("format: the formatted script parses differently")
+
+// Source formats the script src. Scripts with syntax errors are returned
+// unchanged with the kll.Errors of the parser.
+func Source(src string) (string, error) {
+	var p kll.Parser
+	nodes, err := p.Parse(src)
+	if errs, ok := err.(kll.Errors); ok && len(errs) > 0 {
+		return src, errs
+	}
+	var l kll.Lexer
+	tokens, _ := l.Tokenizer(src)
+	pr := &printer{used: used_lines(tokens), closes: closing_lines(tokens)}
+	for _, t := range tokens {
+		if t.Type() == "comment" {
+			pr.comments = append(pr.comments, t)
+		}
+	}
+	file := ast.From_Nodes(nodes)
+	pr.stmts(file.Stmts, math.MaxUint64)
+	if pr.err != nil {
+		return src, pr.err
+	}
+	re := pr.buf.String()
+	if re != "" {
+		re += "\n"
+	}
+	again, err := p.Parse(re)
+	if errs, ok := err.(kll.Errors); ok && len(errs) > 0 || !equal_list(nodes, again) {
+		return src, Err_Changed
+	}
+	return re, nil
+}
+
+// used_lines tells the lines that have a token other than a line break;
+// the others are blank.
+func used_lines(tokens []kll.Token) map[uint64]bool {
+	re := map[uint64]bool{}
+	for _, t := range tokens {
+		if t.Type() == "new line" {
+			continue
+		}
+		span := t.Span()
+		for line := span.Start.Line; line <= span.End.Line; line++ {
+			re[line] = true
+		}
+	}
+	return re
+}
+
+// closing_lines maps the position of each { to the line of the } closing
+// it.
+func closing_lines(tokens []kll.Token) map[ast.Pos]uint64 {
+	re := map[ast.Pos]uint64{}
+	open := []ast.Pos{}
+	for _, t := range tokens {
+		switch t.Type() {
+		case "{":
+			start := t.Span().Start
+			open = append(open, ast.Pos{Line: start.Line, Col: start.Col})
+		case "}":
+			if len(open) > 0 {
+				re[open[len(open)-1]] = t.Span().Start.Line
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return re
+}
+
+type printer struct {
+	buf    strings.Builder
+	depth  int
+	used   map[uint64]bool
+	closes map[ast.Pos]uint64
+	// comments are the comments not written yet, in source order.
+	comments []kll.Token
+	err      error
+}
+
+func (this *printer) write(txt string) {
+	this.buf.WriteString(txt)
+}
+func (this *printer) newline() {
+	this.write("\n" + strings.Repeat(indent, this.depth))
+}
+
+// comment returns the line of the next comment to write, or 0.
+func (this *printer) comment() uint64 {
+	if len(this.comments) == 0 {
+		return 0
+	}
+	return this.comments[0].Span().Start.Line
+}
+func (this *printer) take_comment() string {
+	re := this.comments[0].Value().Re_string("")
+	this.comments = this.comments[1:]
+	return re
+}
+
+// stmts writes a list of statements one per line, with the comments
+// before end, the line of the } closing the list. A blank line is kept
+// where the source had one.
+func (this *printer) stmts(list []ast.Node, end uint64) {
+	prev := uint64(0)
+	line_for := func(line uint64) {
+		if prev != 0 {
+			this.write("\n")
+			if line > prev+1 && !this.used[line-1] {
+				this.write("\n")
+			}
+			this.write(strings.Repeat(indent, this.depth))
+		}
+	}
+	for i, s := range list {
+		line := first_line(s)
+		for c := this.comment(); c != 0 && c < line; c = this.comment() {
+			line_for(c)
+			this.write(this.take_comment())
+			prev = c
+		}
+		line_for(line)
+		this.node(s)
+		if i < len(list)-1 && open_end(s) {
+			// a bare return would take the next line as its value
+			this.write(";")
+		}
+		prev = this.last_line(s)
+		if c := this.comment(); c != 0 && c <= prev && (i == len(list)-1 || first_line(list[i+1]) > c) {
+			this.write(" " + this.take_comment())
+		}
+		if prev < line {
+			prev = line
+		}
+	}
+	for c := this.comment(); c != 0 && c < end; c = this.comment() {
+		line_for(c)
+		this.write(this.take_comment())
+		prev = c
+	}
+}
+
+// first_line is the line of the first token of node.
+func first_line(node ast.Node) uint64 {
+	re := uint64(0)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if line := n.Position().Line; line != 0 && (re == 0 || line < re) {
+			re = line
+		}
+		return true
+	})
+	return re
+}
+
+// last_line is the line of the last token of node, as far as the tree
+// tells: the closing } of blocks and the end of strings are counted, a )
+// or ] on a line of its own is not.
+func (this *printer) last_line(node ast.Node) uint64 {
+	re := uint64(0)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		line := n.Position().Line
+		switch n := n.(type) {
+		case *ast.Block:
+			if l, ok := this.closes[n.Pos]; ok {
+				line = l
+			}
+		case *ast.Literal:
+			if n.Value.VType() == "String" {
+				line += uint64(strings.Count(n.Value.Re_string(""), "\n"))
+			}
+		}
+		if line > re {
+			re = line
+		}
+		return true
+	})
+	return re
+}
+
+// open_end tells the statements ending in a keyword without a value, as
+// in a bare return.
+func open_end(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.Empty:
+		return true
+	case *ast.ReturnStmt:
+		return open_end(n.Value)
+	case *ast.YieldExpr:
+		return is_null(n.Value) || open_end(n.Value)
+	case *ast.VarDecl:
+		if n.Value != nil {
+			return open_end(n.Value)
+		}
+		return open_end(n.Target)
+	case *ast.AssignExpr:
+		return open_end(n.Value)
+	case *ast.BinaryExpr:
+		return open_end(n.Right)
+	}
+	return false
+}
+func is_null(node ast.Node) bool {
+	lit, ok := node.(*ast.Literal)
+	return ok && lit.Value.VType() == "Null"
+}
+
+func (this *printer) block(b *ast.Block) {
+	end, ok := this.closes[b.Pos]
+	if !ok {
+		end = b.Line
+	}
+	if len(b.Stmts) == 0 && (this.comment() == 0 || this.comment() >= end) {
+		this.write("{}")
+		return
+	}
+	this.write("{")
+	if c := this.comment(); c != 0 && c == b.Line && (len(b.Stmts) == 0 || first_line(b.Stmts[0]) > c) {
+		// a comment after the {
+		this.write(" " + this.take_comment())
+		if len(b.Stmts) == 0 && (this.comment() == 0 || this.comment() >= end) {
+			this.newline()
+			this.write("}")
+			return
+		}
+	}
+	this.depth++
+	this.newline()
+	this.stmts(b.Stmts, end)
+	this.depth--
+	this.newline()
+	this.write("}")
+}
+func (this *printer) list(nodes []ast.Node) {
+	for i, n := range nodes {
+		if i > 0 {
+			this.write(", ")
+		}
+		this.node(n)
+	}
+}
+
+func (this *printer) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Ident:
+		this.write(n.Name)
+	case *ast.Literal:
+		this.literal(n.Value)
+	case *ast.Empty:
+	case *ast.BinaryExpr:
+		this.node(n.Left)
+		this.write(" " + n.Op + " ")
+		this.node(n.Right)
+	case *ast.UnaryExpr:
+		this.write(n.Op)
+		this.node(n.X)
+	case *ast.ParenExpr:
+		this.write("(")
+		this.node(n.X)
+		this.write(")")
+	case *ast.RangeExpr:
+		op := ".."
+		if !n.Inclusive {
+			op = "..<"
+		}
+		_, left := n.Start.(*ast.BinaryExpr)
+		_, right := n.Stop.(*ast.BinaryExpr)
+		if left || right {
+			op = " " + op + " "
+		}
+		this.node(n.Start)
+		this.write(op)
+		this.node(n.Stop)
+	case *ast.ArrayLit:
+		this.write("[")
+		this.list(n.Elems)
+		this.write("]")
+	case *ast.SpreadExpr:
+		this.write("...")
+		this.node(n.X)
+	case *ast.AttrExpr:
+		this.node(n.X)
+		this.write("." + n.Name)
+	case *ast.CallExpr:
+		this.node(n.Fun)
+		this.write("(")
+		this.list(n.Args)
+		this.write(")")
+	case *ast.KeywordArg:
+		this.write(n.Name + " = ")
+		this.node(n.Value)
+	case *ast.AssignExpr:
+		this.node(n.Target)
+		this.write(" = ")
+		this.node(n.Value)
+	case *ast.VarDecl:
+		if n.Global {
+			this.write("global")
+		} else {
+			this.write("var")
+		}
+		if _, ok := n.Target.(*ast.Empty); !ok {
+			this.write(" ")
+			this.node(n.Target)
+		}
+		if n.Value != nil {
+			this.write(" = ")
+			this.node(n.Value)
+		}
+	case *ast.FuncLit:
+		this.write("function")
+		if n.Generator {
+			this.write("*")
+		}
+		if n.Name != "" {
+			this.write(" " + n.Name)
+		}
+		this.write("(")
+		this.list(n.Params)
+		this.write(") ")
+		this.block(n.Body)
+	case *ast.Block:
+		this.block(n)
+	case *ast.IfStmt:
+		this.write("if ")
+		this.node(n.Cond)
+		this.write(" ")
+		this.block(n.Body)
+	case *ast.ForStmt:
+		this.write("for ")
+		this.node(n.Target)
+		this.write(" in ")
+		this.node(n.Iter)
+		this.write(" ")
+		this.block(n.Body)
+	case *ast.ReturnStmt:
+		this.write("return")
+		if _, ok := n.Value.(*ast.Empty); !ok {
+			this.write(" ")
+			this.node(n.Value)
+		}
+	case *ast.YieldExpr:
+		this.write("yield")
+		if !is_null(n.Value) {
+			this.write(" ")
+			this.node(n.Value)
+		}
+	case *ast.ExistExpr:
+		this.write("exist " + n.Name.Name)
+	default:
+		if this.err == nil {
+			pos := node.Position()
+			this.err = fmt.Errorf("format: %d:%d: can't print %T", pos.Line, pos.Col, node)
+		}
+	}
+}
+
+// literal writes a value the way the lexer reads it back: numbers without
+// exponents and null as ().
+func (this *printer) literal(v kll.Value) {
+	switch v.VType() {
+	case "Number":
+		this.write(strconv.FormatFloat(v.Re_number(), 'f', -1, 64))
+	case "String":
+		this.write("\"" + v.Re_string("") + "\"")
+	case "Null":
+		this.write("()")
+	default:
+		this.write(v.Re_string(""))
+	}
+}
+
+// equal compares two trees of the parser, ignoring the positions and the
+// slots kll.Resolve adds to variables.
+func equal(a, b kll.Value) bool {
+	na, ok1 := a.(kll.Node)
+	nb, ok2 := b.(kll.Node)
+	if ok1 != ok2 {
+		return false
+	}
+	if !ok1 {
+		return a.VType() == b.VType() && a.Re_string("") == b.Re_string("") && a.Re_number() == b.Re_number()
+	}
+	if na.Tp != nb.Tp {
+		return false
+	}
+	va, vb := na.Value, nb.Value
+	if na.Tp == "var" && len(va) > 0 && len(vb) > 0 {
+		va, vb = va[:1], vb[:1]
+	}
+	return equal_list(va, vb)
+}
+func equal_list(a, b []kll.Value) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
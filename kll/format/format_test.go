@@ -0,0 +1,127 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+var golden = []struct {
+	name string
+	src  string
+	want string
+}{
+	{name: "spacing", src: `var a=1+2
+function  f(x ,y){
+  return x*y
+}
+`, want: `var a = 1 + 2
+function f(x, y) {
+    return x * y
+}
+`},
+	{name: "keywords", src: `local a = true and false or true
+`, want: `var a = true && false || true
+`},
+	{name: "blank lines", src: `var a = 1
+
+
+
+var b = 2
+var c = 3
+`, want: `var a = 1
+
+var b = 2
+var c = 3
+`},
+	{name: "comment before close brace", src: `if true {
+// only a comment
+}
+function f() {
+  var a = 1
+    // after a
+}
+`, want: `if true {
+    // only a comment
+}
+function f() {
+    var a = 1
+    // after a
+}
+`},
+	{name: "trailing comments", src: `var a = 1 // one
+function f() { // opens
+  return a // returns
+} // closes
+if true { // empty
+}
+`, want: `var a = 1 // one
+function f() { // opens
+    return a // returns
+} // closes
+if true { // empty
+}
+`},
+	{name: "comment in a call", src: `console.log(1,
+  // two
+  2)
+var b = 3
+`, want: `console.log(1, 2) // two
+var b = 3
+`},
+	{name: "bare return", src: `function f(x) {
+  if x {
+    return
+  }
+  return;console.log(x)
+}
+`, want: `function f(x) {
+    if x {
+        return
+    }
+    return;
+    console.log(x)
+}
+`},
+	{name: "ranges", src: `var a = 1+2..<3*4
+var b = 0..a
+var c = (1..2)
+`, want: `var a = 1 + 2 ..< 3 * 4
+var b = 0..a
+var c = (1..2)
+`},
+}
+
+func TestSource(t *testing.T) {
+	for _, test := range golden {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Source(test.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, test.want)
+			}
+			again, err := Source(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != got {
+				t.Fatalf("formatting is not idempotent:\n%s\nbecame:\n%s", got, again)
+			}
+			if n, m := strings.Count(test.src, "//"), strings.Count(got, "//"); n != m {
+				t.Fatalf("%d comments became %d", n, m)
+			}
+		})
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	src := "var a = 1 +\n"
+	got, err := Source(src)
+	if err == nil {
+		t.Fatalf("got %q, want an error", got)
+	}
+	if got != src {
+		t.Fatalf("got %q, want the source unchanged", got)
+	}
+}
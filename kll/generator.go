@@ -0,0 +1,240 @@
+package kll
+
+import "runtime"
+
+// Generator is the value returned by calling a function*. Its body runs on
+// its own goroutine and hands control back and forth with the caller
+// through channels, so only one side runs at a time.
+//
+// The goroutine only references generator_state. The Generator values
+// handed to scripts share a generator_handle with a finalizer, so a
+// generator that is dropped before finishing is cancelled by the garbage
+// collector instead of leaking its goroutine.
+type Generator struct {
+	VTp    string `json:"value type"`
+	handle *generator_handle
+}
+type generator_handle struct {
+	state *generator_state
+}
+type generator_state struct {
+	inter *Interpreter
+	// calls and module are the calls of the body and the module it runs
+	// in, kept here while it is suspended so the ones of the interpreter
+	// stay those of the caller.
+	calls    []call
+	module   string
+	body     func(gen *generator_state) (Value, any)
+	resume   chan Value
+	yield    chan generator_msg
+	cancel   chan struct{}
+	exited   chan struct{}
+	started  bool
+	finished bool
+}
+type generator_msg struct {
+	value Value
+	done  bool
+	err   any
+}
+type generator_exit struct{}
+
+func (this Generator) On_sum(value Value) Value {
+	return Create_Null()
+}
+func (this Generator) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this Generator) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this Generator) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this Generator) Re_string(prefix string) string {
+	return "<generator>"
+}
+func (this Generator) Re_number() float64 {
+	return -1
+}
+func (this Generator) Re_bool() bool {
+	return !this.handle.state.finished
+}
+func (this Generator) VType() string {
+	return "Generator"
+}
+func (this Generator) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	return Create_Null(), nil
+}
+func (this Generator) On_get_attr(name string) Value {
+	switch name {
+	case "next":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			send, ok := get_arg(args, kwargs, 0, "value")
+			if !ok {
+				send = Create_Null()
+			}
+			v, ok, err := this.handle.state.next(send)
+			if err != nil {
+				return Create_Null(), err
+			}
+			return Create_Object(map[string]Value{"value": v, "done": Create_Bool(!ok)}), nil
+		})
+	case "return":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			v, ok := get_arg(args, kwargs, 0, "value")
+			if !ok {
+				v = Create_Null()
+			}
+			this.handle.state.stop()
+			return Create_Object(map[string]Value{"value": v, "done": Create_Bool(true)}), nil
+		})
+	case "to_array":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			values, err := Collect(this)
+			if err != nil {
+				return Create_Null(), err
+			}
+			return Create_Array(values), nil
+		})
+	}
+	return Create_Null()
+}
+func (this Generator) Attr_Names() []string {
+	return []string{"next", "return", "to_array"}
+}
+func (this Generator) On_set_attr(name string, value Value) Value {
+	return Create_Null()
+}
+func (this Generator) On_in(name Value) Value {
+	return Create_Bool(false)
+}
+func (this Generator) Re_hash() (string, bool) {
+	return "", false
+}
+func (this Generator) On_iter() (Iterator, any) {
+	return this, nil
+}
+func (this Generator) On_next() (Value, bool, any) {
+	v, ok, err := this.handle.state.next(Create_Null())
+	if !ok {
+		v = nil
+	}
+	return v, ok && err == nil, err
+}
+func (this Generator) On_close() {
+	this.handle.state.stop()
+}
+
+func Create_Generator(function Function, args []Value, kwargs map[string]*Variable) Value {
+	return create_generator(function.inter, func(gen *generator_state) (Value, any) {
+		return function.call(args, kwargs, gen)
+	})
+}
+
+// create_generator wraps body, which runs on the generator goroutine and
+// gives gen to the frame of the call whose yields call yield_value, in a
+// Generator.
+func create_generator(inter *Interpreter, body func(gen *generator_state) (Value, any)) Value {
+	state := &generator_state{
+		inter:  inter,
+		body:   body,
+		resume: make(chan Value),
+		yield:  make(chan generator_msg),
+		cancel: make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+	handle := &generator_handle{state: state}
+	runtime.SetFinalizer(handle, func(h *generator_handle) {
+		h.state.stop()
+	})
+	re := Generator{handle: handle}
+	re.VTp = re.VType()
+	return re
+}
+
+// next runs the body until the next yield, or to the end, and returns the
+// yielded value; ok is false once the body has returned.
+func (this *generator_state) next(send Value) (Value, bool, any) {
+	if this.finished {
+		return Create_Null(), false, nil
+	}
+	// the body runs on top of the calls of whoever resumes it
+	calls, module := this.inter.calls, this.inter.module
+	this.inter.calls = append(calls[:len(calls):len(calls)], this.calls...)
+	if !this.started {
+		this.started = true
+		go this.run()
+	} else {
+		this.inter.module = this.module
+		this.resume <- send
+	}
+	msg := <-this.yield
+	this.calls = append([]call{}, this.inter.calls[len(calls):]...)
+	this.module = this.inter.module
+	this.inter.calls, this.inter.module = calls, module
+	if msg.done || msg.err != nil {
+		this.finished = true
+		<-this.exited
+		return msg.value, false, msg.err
+	}
+	return msg.value, true, nil
+}
+func (this *generator_state) run() {
+	defer close(this.exited)
+	re, err := this.body(this)
+	if is_generator_exit(err) {
+		return
+	}
+	if re == nil {
+		re = Create_Null()
+	}
+	select {
+	case this.yield <- generator_msg{value: re, done: true, err: err}:
+	case <-this.cancel:
+	}
+}
+
+// yield_value is called from the generator goroutine by the "yield" node.
+// It returns the value passed to the next call of next, or generator_exit
+// when the generator is being closed.
+func (this *generator_state) yield_value(value Value) (Value, any) {
+	select {
+	case this.yield <- generator_msg{value: value}:
+	case <-this.cancel:
+		return nil, generator_exit{}
+	}
+	select {
+	case v := <-this.resume:
+		return v, nil
+	case <-this.cancel:
+		return nil, generator_exit{}
+	}
+}
+
+// stop unwinds a suspended body and waits for its goroutine to exit. It
+// may be called by the finalizer while the interpreter runs something else,
+// so the unwinding leaves the calls and module of the interpreter alone.
+func (this *generator_state) stop() {
+	if this.finished {
+		return
+	}
+	this.finished = true
+	if this.started {
+		close(this.cancel)
+		<-this.exited
+	}
+}
+func is_generator_exit(err any) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case generator_exit:
+			return true
+		case Error:
+			err = e.other_error
+		default:
+			return false
+		}
+	}
+	return false
+}
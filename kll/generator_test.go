@@ -0,0 +1,180 @@
+package kll
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+// traceback_functions runs src on both engines and returns the functions
+// of the traceback of the error it raises.
+func traceback_functions(t *testing.T, src string) [2][]string {
+	re := [2][]string{}
+	for i, vm := range []bool{false, true} {
+		inter := Interpreter{Use_VM: vm}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		_, err := inter.Eval(src, &locals)
+		var e Error
+		if !errors.As(err, &e) {
+			t.Fatalf("vm=%v: got %v, want an Error", vm, err)
+		}
+		for _, f := range e.Traceback() {
+			re[i] = append(re[i], f.Function)
+		}
+		runtime.GC()
+	}
+	return re
+}
+
+func TestGeneratorTraceback(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{name: "after next", source: `function* g() {
+	yield 1
+	yield 2
+}
+function outer() {
+	var it = g()
+	it.next()
+	for x in 5 {
+	}
+}
+outer()`, want: []string{"<module>", "outer"}},
+		{name: "next in a helper", source: `function* g() {
+	yield 1
+	for x in 5 {
+	}
+}
+function helper(it) {
+	return it.next()
+}
+function main() {
+	var it = g()
+	helper(it)
+	it.next()
+}
+main()`, want: []string{"<module>", "main", "it.next", "g"}},
+		{name: "after return and break", source: `function* g() {
+	yield 1
+	yield 2
+}
+function first() {
+	for v in g() {
+		return v
+	}
+}
+function outer() {
+	var it = g()
+	it.next()
+	it.return()
+	first()
+	for x in 5 {
+	}
+}
+outer()`, want: []string{"<module>", "outer"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, got := range traceback_functions(t, test.source) {
+				if len(got) != len(test.want) {
+					t.Fatalf("vm=%v: got %v, want %v", i == 1, got, test.want)
+				}
+				for j := range got {
+					if got[j] != test.want[j] {
+						t.Fatalf("vm=%v: got %v, want %v", i == 1, got, test.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestYieldOutsideGenerator(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		line   uint64
+	}{
+		{name: "module", source: `yield 1`, line: 1},
+		{name: "helper", source: `function helper() {
+	yield 5
+}
+function* g() {
+	helper()
+	yield 1
+}
+[...g()]`, line: 2},
+		{name: "callback", source: `function* g() {
+	[1, 2].map(function(x) {
+		yield x
+	})
+	yield 3
+}
+[...g()]`, line: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := (&Parser{}).Parse(test.source)
+			errs, ok := err.(Errors)
+			if !ok || len(errs) != 1 || !errors.Is(errs[0], Code_Yield_Outside_Generator) || errs[0].line != test.line {
+				t.Fatalf("got %v, want %s at line %d", err, Code_Yield_Outside_Generator, test.line)
+			}
+		})
+	}
+}
+
+// TestYieldBelongsToItsFrame checks that a yield reached by a call made
+// from a generator doesn't hand its value to that generator, even when the
+// parser was bypassed.
+func TestYieldBelongsToItsFrame(t *testing.T) {
+	src := `function* helper() {
+	yield 5
+}
+function* g() {
+	helper()
+	yield 1
+}
+[...g()]`
+	for _, vm := range []bool{false, true} {
+		nodes, err := (&Parser{}).Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		helper := nodes[0].(Node)
+		nodes[0] = Create_Node(helper.Value, "function", helper.Line, helper.Col)
+		inter := Interpreter{Use_VM: vm}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		v, err := inter.run(nodes, &locals)
+		if !errors.Is(to_error(err), Code_Yield_Outside_Generator) {
+			t.Errorf("vm=%v: got %v, %v, want %s", vm, v, err, Code_Yield_Outside_Generator)
+		}
+	}
+}
+
+func TestNestedGenerators(t *testing.T) {
+	src := `function* outer() {
+	function* inner() {
+		yield 1
+		yield 2
+	}
+	for v in inner() {
+		yield v * 10
+	}
+	yield 3
+}
+[...outer()]`
+	for _, vm := range []bool{false, true} {
+		inter := Interpreter{Use_VM: vm}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		v, err := inter.Eval(src, &locals)
+		if err != nil || v.Re_string("") != "[10, 20, 3]" {
+			t.Errorf("vm=%v: got %v, %v, want [10, 20, 3]", vm, v, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package kll
+
+// Iterable is implemented by values that can be walked by for-in loops,
+// spread and destructuring.
+type Iterable interface {
+	Value
+	On_iter() (Iterator, any)
+}
+
+// Iterator yields one value per On_next call until ok is false. On_close
+// is called when the consumer stops before the end.
+type Iterator interface {
+	Value
+	On_next() (value Value, ok bool, err any)
+	On_close()
+}
+
+// GoIterator is the Iterator used by the built-in collections. Go hosts
+// can build one with Create_GoIterator to expose lazy streams, such as
+// database rows, without collecting them into an Array first.
+type GoIterator struct {
+	VTp   string `json:"value type"`
+	next  func() (Value, bool, any)
+	close func()
+	done  *bool
+}
+
+func (this GoIterator) On_sum(value Value) Value {
+	return Create_Null()
+}
+func (this GoIterator) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this GoIterator) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this GoIterator) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this GoIterator) Re_string(prefix string) string {
+	return "<iterator>"
+}
+func (this GoIterator) Re_number() float64 {
+	return -1
+}
+func (this GoIterator) Re_bool() bool {
+	return !*this.done
+}
+func (this GoIterator) VType() string {
+	return "Iterator"
+}
+func (this GoIterator) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	return Create_Null(), nil
+}
+func (this GoIterator) On_get_attr(name string) Value {
+	switch name {
+	case "next":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			v, ok, err := this.On_next()
+			if err != nil {
+				return Create_Null(), err
+			}
+			if !ok {
+				v = Create_Null()
+			}
+			return Create_Object(map[string]Value{"value": v, "done": Create_Bool(!ok)}), nil
+		})
+	case "close":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			this.On_close()
+			return Create_Null(), nil
+		})
+	case "to_array":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			values, err := Collect(this)
+			if err != nil {
+				return Create_Null(), err
+			}
+			return Create_Array(values), nil
+		})
+	}
+	return Create_Null()
+}
+func (this GoIterator) Attr_Names() []string {
+	return []string{"next", "close", "to_array"}
+}
+func (this GoIterator) On_set_attr(name string, value Value) Value {
+	return Create_Null()
+}
+func (this GoIterator) On_in(name Value) Value {
+	return Create_Bool(false)
+}
+func (this GoIterator) Re_hash() (string, bool) {
+	return "", false
+}
+func (this GoIterator) On_iter() (Iterator, any) {
+	return this, nil
+}
+func (this GoIterator) On_next() (Value, bool, any) {
+	if *this.done {
+		return nil, false, nil
+	}
+	v, ok, err := this.next()
+	if !ok || err != nil {
+		this.On_close()
+	}
+	return v, ok && err == nil, err
+}
+func (this GoIterator) On_close() {
+	if *this.done {
+		return
+	}
+	*this.done = true
+	if this.close != nil {
+		this.close()
+	}
+}
+
+func Create_GoIterator(next func() (Value, bool, any), close func()) Value {
+	done := false
+	re := GoIterator{next: next, close: close, done: &done}
+	re.VTp = re.VType()
+	return re
+}
+func values_iterator(values func() []Value) Iterator {
+	i := 0
+	return Create_GoIterator(func() (Value, bool, any) {
+		items := values()
+		if i >= len(items) {
+			return nil, false, nil
+		}
+		i++
+		return items[i-1], true, nil
+	}, nil).(GoIterator)
+}
+
+// Iter returns the iterator of value, or an error when it is not iterable.
+func Iter(value Value) (Iterator, any) {
+	if v, ok := value.(Iterable); ok {
+		return v.On_iter()
+	}
+	return nil, Error{code: Code_Not_Iterable, kind: "erro3", key: "erro msg13", args: []string{value.Re_string("")}}
+}
+
+// Collect drains value into a slice.
+func Collect(value Value) ([]Value, any) {
+	it, err := Iter(value)
+	if err != nil {
+		return nil, err
+	}
+	re := []Value{}
+	for {
+		v, ok, err := it.On_next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return re, nil
+		}
+		re = append(re, v)
+	}
+}
+
+func (this Array) On_iter() (Iterator, any) {
+	return values_iterator(func() []Value {
+		return this.Items()
+	}), nil
+}
+func (this Tuple) On_iter() (Iterator, any) {
+	return values_iterator(func() []Value {
+		return this.Value
+	}), nil
+}
+func (this String) On_iter() (Iterator, any) {
+	runes := []rune(this.Value)
+	i := 0
+	return Create_GoIterator(func() (Value, bool, any) {
+		if i >= len(runes) {
+			return nil, false, nil
+		}
+		i++
+		return Create_String(string(runes[i-1])), true, nil
+	}, nil).(GoIterator), nil
+}
+func (this Object) On_iter() (Iterator, any) {
+	keys := this.Keys()
+	i := 0
+	return Create_GoIterator(func() (Value, bool, any) {
+		for i < len(keys) {
+			k := keys[i]
+			i++
+			if v, ok := this.value.Get(k); ok {
+				return Create_Array([]Value{Create_String(k), v.Value}), true, nil
+			}
+		}
+		return nil, false, nil
+	}, nil).(GoIterator), nil
+}
+func (this Map) On_iter() (Iterator, any) {
+	keys := this.keys.Keys()
+	i := 0
+	return Create_GoIterator(func() (Value, bool, any) {
+		for i < len(keys) {
+			h := keys[i]
+			i++
+			k, ok := this.keys.Get(h)
+			if !ok {
+				continue
+			}
+			v, _ := this.values.Get(h)
+			return Create_Array([]Value{k.Value, v.Value}), true, nil
+		}
+		return nil, false, nil
+	}, nil).(GoIterator), nil
+}
+func (this Set) On_iter() (Iterator, any) {
+	keys := this.values.Keys()
+	i := 0
+	return Create_GoIterator(func() (Value, bool, any) {
+		for i < len(keys) {
+			h := keys[i]
+			i++
+			if v, ok := this.values.Get(h); ok {
+				return v.Value, true, nil
+			}
+		}
+		return nil, false, nil
+	}, nil).(GoIterator), nil
+}
+func (this Pointer) On_iter() (Iterator, any) {
+	return Iter(this.value.Value)
+}
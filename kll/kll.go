@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type Value interface {
@@ -22,6 +25,7 @@ type Value interface {
 	On_get_attr(name string) Value
 	On_set_attr(name string, value Value) Value
 	On_in(name Value) Value
+	Re_hash() (string, bool)
 	//On_get_variable(name string) *Value
 	VType() string
 }
@@ -48,11 +52,16 @@ type Bool struct {
 	Value bool
 }
 type Function struct {
-	VTp    string `json:"value type"`
-	nodes  []Value
-	inter  *Interpreter
-	locals *Object
-	args   []Variable
+	VTp       string `json:"value type"`
+	nodes     []Value
+	inter     *Interpreter
+	locals    *Object
+	args      []Variable
+	generator bool
+	env       *frame
+	slots     int
+	name      string
+	module    string
 }
 type GoFunction struct {
 	VTp      string `json:"value type"`
@@ -64,15 +73,27 @@ type Pointer struct {
 }
 type Object struct {
 	VTp   string `json:"value type"`
-	value map[string]Variable
+	value *OrderedMap
+	meta  *object_meta
 }
+type object_meta struct {
+	frozen bool
+	parent *Object
+	// module is the frame of the variables of the scripts run with the
+	// Object as their locals, which have to see what is set in it.
+	module *frame
+}
+
+// Array is a script array. Value points at the elements so that every copy
+// of an Array sees what push, pop, shift, unshift and splice do to it; this
+// is a change from the []Value it used to be, so embedders build arrays
+// with Create_Array and read them with Items. A nil Value is an empty
+// array, and what the methods of such an array add to it is lost.
 type Array struct {
 	VTp   string `json:"value type"`
-	Value []Value
+	Value *[]Value
 }
 
-var lang = "pt-br"
-
 func (this Number) On_sum(value Value) Value {
 	return Create_Number(this.Re_number() + value.Re_number())
 }
@@ -125,12 +146,18 @@ func (this Number) On_get_attr(name string) Value {
 	}
 	return Create_Null()
 }
+func (this Number) Attr_Names() []string {
+	return []string{"string", "is_int", "length", "length1", "length2", "decimal"}
+}
 func (this Number) On_set_attr(name string, value Value) Value {
 	return Create_Null()
 }
 func (this Number) On_in(name Value) Value {
 	return Create_Bool(false)
 }
+func (this Number) Re_hash() (string, bool) {
+	return "n:" + strconv.FormatFloat(this.Value, 'g', -1, 64), true
+}
 
 func (this Node) On_sum(value Value) Value {
 	return Create_Null()
@@ -191,6 +218,9 @@ func (this Node) On_set_attr(name string, value Value) Value {
 func (this Node) On_in(name Value) Value {
 	return Create_Bool(false)
 }
+func (this Node) Re_hash() (string, bool) {
+	return "", false
+}
 
 func (this String) On_sum(value Value) Value {
 	return Create_String(this.Re_string("") + value.Re_string(""))
@@ -202,7 +232,14 @@ func (this String) On_div(value Value) Value {
 	return nil
 }
 func (this String) On_mul(value Value) Value {
-	return nil
+	if value.VType() != "Number" {
+		return nil
+	}
+	n := int(value.Re_number())
+	if n < 0 {
+		n = 0
+	}
+	return Create_String(strings.Repeat(this.Value, n))
 }
 func (this String) Re_string(prefix string) string {
 	return this.Value
@@ -224,7 +261,7 @@ func (this String) On_get_attr(name string) Value {
 	case "number":
 		return To_int(this.Re_string(""))
 	case "length":
-		return Create_Number(float64(len(this.Re_string(""))))
+		return Create_Number(float64(utf8.RuneCountInString(this.Value)))
 	case "replace":
 		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
 			old := ""
@@ -267,15 +304,197 @@ func (this String) On_get_attr(name string) Value {
 			}
 			return Create_Bool(strings.HasSuffix(this.Re_string(""), value)), nil
 		})
+	case "split":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			sep := ""
+			if v, ok := get_arg(args, kwargs, 0, "sep"); ok {
+				sep = v.Re_string("")
+			}
+			limit := -1
+			if v, ok := get_arg(args, kwargs, 1, "limit"); ok {
+				limit = int(v.Re_number())
+			}
+			re := []Value{}
+			for _, str := range strings.SplitN(this.Value, sep, limit) {
+				re = append(re, Create_String(str))
+			}
+			return Create_Array(re), nil
+		})
+	case "trim", "trim_start", "trim_end":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			chars := " \t\n\r"
+			if v, ok := get_arg(args, kwargs, 0, "chars"); ok {
+				chars = v.Re_string("")
+			}
+			switch name {
+			case "trim_start":
+				return Create_String(strings.TrimLeft(this.Value, chars)), nil
+			case "trim_end":
+				return Create_String(strings.TrimRight(this.Value, chars)), nil
+			}
+			return Create_String(strings.Trim(this.Value, chars)), nil
+		})
+	case "upper":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_String(strings.ToUpper(this.Value)), nil
+		})
+	case "lower":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_String(strings.ToLower(this.Value)), nil
+		})
+	case "index_of":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			value := ""
+			if v, ok := get_arg(args, kwargs, 0, "value"); ok {
+				value = v.Re_string("")
+			}
+			runes := []rune(this.Value)
+			start := 0
+			if v, ok := get_arg(args, kwargs, 1, "start"); ok {
+				start = array_index(v, len(runes))
+			}
+			i := strings.Index(string(runes[start:]), value)
+			if i == -1 {
+				return Create_Number(-1), nil
+			}
+			return Create_Number(float64(start + utf8.RuneCountInString(string(runes[start:])[:i]))), nil
+		})
+	case "last_index_of":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			value := ""
+			if v, ok := get_arg(args, kwargs, 0, "value"); ok {
+				value = v.Re_string("")
+			}
+			i := strings.LastIndex(this.Value, value)
+			if i == -1 {
+				return Create_Number(-1), nil
+			}
+			return Create_Number(float64(utf8.RuneCountInString(this.Value[:i]))), nil
+		})
+	case "substring":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			runes := []rune(this.Value)
+			start, end := 0, len(runes)
+			if v, ok := get_arg(args, kwargs, 0, "start"); ok {
+				start = array_index(v, len(runes))
+			}
+			if v, ok := get_arg(args, kwargs, 1, "end"); ok {
+				end = array_index(v, len(runes))
+			}
+			if end < start {
+				start, end = end, start
+			}
+			return Create_String(string(runes[start:end])), nil
+		})
+	case "repeat":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			count := Create_Number(0)
+			if v, ok := get_arg(args, kwargs, 0, "count"); ok {
+				count = v
+			}
+			return this.On_mul(count), nil
+		})
+	case "pad_start", "pad_end":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			length := 0
+			if v, ok := get_arg(args, kwargs, 0, "length"); ok {
+				length = int(v.Re_number())
+			}
+			fill := " "
+			if v, ok := get_arg(args, kwargs, 1, "fill"); ok {
+				fill = v.Re_string("")
+			}
+			missing := length - utf8.RuneCountInString(this.Value)
+			if missing <= 0 || fill == "" {
+				return this, nil
+			}
+			pad := []rune(strings.Repeat(fill, missing/utf8.RuneCountInString(fill)+1))[:missing]
+			if name == "pad_start" {
+				return Create_String(string(pad) + this.Value), nil
+			}
+			return Create_String(this.Value + string(pad)), nil
+		})
+	case "chars":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			re := []Value{}
+			for _, r := range this.Value {
+				re = append(re, Create_String(string(r)))
+			}
+			return Create_Array(re), nil
+		})
+	case "format":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			return Create_String(format_string(this.Value, args, kwargs)), nil
+		})
 	}
 	return Create_Null()
 }
+func (this String) Attr_Names() []string {
+	return []string{"number", "length", "replace", "startswith", "endswith", "split", "trim", "trim_start", "trim_end", "upper", "lower", "index_of", "last_index_of", "substring", "repeat", "pad_start", "pad_end", "chars", "format"}
+}
+
+// format_string replaces {} with the next positional argument, {n} with the
+// argument at index n and {name} with the keyword argument name. {{ and }}
+// write a literal brace.
+func format_string(str string, args []Value, kwargs map[string]*Variable) string {
+	re := strings.Builder{}
+	next := 0
+	runes := []rune(str)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '}' && i+1 < len(runes) && runes[i+1] == '}' {
+			re.WriteRune('}')
+			i++
+			continue
+		}
+		if runes[i] != '{' {
+			re.WriteRune(runes[i])
+			continue
+		}
+		if i+1 < len(runes) && runes[i+1] == '{' {
+			re.WriteRune('{')
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && runes[end] != '}' {
+			end++
+		}
+		if end >= len(runes) {
+			re.WriteString(string(runes[i:]))
+			break
+		}
+		key := string(runes[i+1 : end])
+		var v Value
+		if key == "" {
+			if next < len(args) {
+				v = args[next]
+			}
+			next++
+		} else if n, err := strconv.Atoi(key); err == nil {
+			if n >= 0 && n < len(args) {
+				v = args[n]
+			}
+		} else if kw, ok := kwargs[key]; ok {
+			v = kw.Value
+		}
+		if v == nil {
+			re.WriteString(string(runes[i : end+1]))
+		} else {
+			re.WriteString(v.Re_string(""))
+		}
+		i = end
+	}
+	return re.String()
+}
 func (this String) On_set_attr(name string, value Value) Value {
 	return Create_Null()
 }
 func (this String) On_in(name Value) Value {
 	return Create_Bool(strings.Index(this.Value, name.Re_string("")) != -1)
 }
+func (this String) Re_hash() (string, bool) {
+	return "s" + fmt.Sprint(len(this.Value)) + ":" + this.Value, true
+}
 
 func (this Null) On_sum(value Value) Value {
 	return Create_Null()
@@ -309,12 +528,18 @@ func (this Null) On_get_attr(name string) Value {
 	}
 	return Create_Null()
 }
+func (this Null) Attr_Names() []string {
+	return []string{}
+}
 func (this Null) On_set_attr(name string, value Value) Value {
 	return Create_Null()
 }
 func (this Null) On_in(name Value) Value {
 	return Create_Bool(false)
 }
+func (this Null) Re_hash() (string, bool) {
+	return "null", true
+}
 
 func (this Function) On_sum(value Value) Value {
 	return Create_Null()
@@ -347,10 +572,24 @@ func (this Function) VType() string {
 	return "Function"
 }
 func (this Function) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	if this.generator {
+		return Create_Generator(this, args, kwargs), nil
+	}
+	return this.call(args, kwargs, nil)
+}
+
+// call runs the body of the function, as the body of the generator gen
+// when it is not nil.
+func (this Function) call(args []Value, kwargs map[string]*Variable, gen *generator_state) (Value, any) {
+	from_go := !this.inter.entered()
+	if from_go {
+		this.inter.push_call(this, nil, 0, 0)
+	}
+	module := this.inter.module
+	this.inter.module = this.module
+	env := new_frame(this.slots, this.env)
+	env.gen = gen
 	for i := range this.args {
-		if i > len(this.args) {
-			break
-		}
 		v := this.args[i].Value
 		if i < len(args) {
 			v = args[i]
@@ -359,26 +598,33 @@ func (this Function) On_call(args []Value, kwargs map[string]*Variable, pos int)
 		if ok {
 			v = v2.Value
 		}
-		this.locals.Create_Var(this.args[i].name, pos+1, v, false)
+		if i < len(env.slots) {
+			env.slots[i] = v
+		} else {
+			this.locals.Create_Var(this.args[i].name, 0, v, false)
+		}
 	}
 	re := Create_Null()
 	var err any = nil
 	i := uint64(0)
 	for i < uint64(len(this.nodes)) {
-		if this.nodes[i].(Node).Tp == "return" {
-			re, err = this.inter.exec_node(this.nodes[i].(Node).Value[0], this.locals, pos+1)
+		_, e := this.inter.exec_node(this.nodes[i], this.locals, env)
+		if r, ok := e.(return_signal); ok {
+			re = r.value
 			break
 		}
-		_, e := this.inter.exec_node(this.nodes[i], this.locals, pos+1)
 		if e != nil {
 			err = e
+			break
 		}
 		i++
 	}
-	for v := range this.locals.value {
-		if this.locals.value[v].Pos >= pos+1 {
-			delete(this.locals.value, v)
-		}
+	if is_generator_exit(err) {
+		return re, err
+	}
+	this.inter.module = module
+	if from_go {
+		err = this.inter.end_call(err)
 	}
 	return re, err
 }
@@ -387,12 +633,18 @@ func (this Function) On_get_attr(name string) Value {
 	}
 	return Create_Null()
 }
+func (this Function) Attr_Names() []string {
+	return []string{}
+}
 func (this Function) On_set_attr(name string, value Value) Value {
 	return Create_Null()
 }
 func (this Function) On_in(name Value) Value {
 	return Create_Bool(false)
 }
+func (this Function) Re_hash() (string, bool) {
+	return "", false
+}
 
 func (this GoFunction) On_sum(value Value) Value {
 	return Create_Null()
@@ -427,12 +679,18 @@ func (this GoFunction) On_get_attr(name string) Value {
 	}
 	return Create_Null()
 }
+func (this GoFunction) Attr_Names() []string {
+	return []string{}
+}
 func (this GoFunction) On_set_attr(name string, value Value) Value {
 	return Create_Null()
 }
 func (this GoFunction) On_in(name Value) Value {
 	return Create_Bool(false)
 }
+func (this GoFunction) Re_hash() (string, bool) {
+	return "", false
+}
 
 func (this Bool) On_sum(value Value) Value {
 	return Create_Null()
@@ -469,12 +727,18 @@ func (this Bool) On_get_attr(name string) Value {
 	}
 	return Create_Null()
 }
+func (this Bool) Attr_Names() []string {
+	return []string{}
+}
 func (this Bool) On_set_attr(name string, value Value) Value {
 	return Create_Null()
 }
 func (this Bool) On_in(name Value) Value {
 	return Create_Bool(false)
 }
+func (this Bool) Re_hash() (string, bool) {
+	return "b:" + this.Re_string(""), true
+}
 
 func (this Object) On_sum(value Value) Value {
 	return Create_Null()
@@ -491,19 +755,20 @@ func (this Object) On_mul(value Value) Value {
 func (this Object) Re_string(prefix string) string {
 	re := "{"
 	pos := 0
-	for i := range this.value {
+	this.value.Each(func(i string, v Variable) bool {
 		if pos > 0 {
 			re += ", "
 		}
 		re += i + ":"
-		switch this.value[i].Value.VType() {
+		switch v.Value.VType() {
 		case "String":
-			re += string('"') + this.value[i].Value.Re_string("") + string('"')
+			re += string('"') + v.Value.Re_string("") + string('"')
 		default:
-			re += this.value[i].Value.Re_string("")
+			re += v.Value.Re_string("")
 		}
 		pos++
-	}
+		return true
+	})
 	return re + "}"
 }
 func (this Object) Re_number() float64 {
@@ -521,36 +786,90 @@ func (this Object) On_call(args []Value, kwargs map[string]*Variable, pos int) (
 func (this Object) On_get_attr(name string) Value {
 	switch name {
 	default:
-		return this.value[name].Value
+		if v, ok := this.value.Get(name); ok {
+			return v.Value
+		}
 	}
-	//return Create_Null()
+	return Create_Null()
+}
+func (this Object) Attr_Names() []string {
+	return this.Keys()
 }
 func (this Object) On_set_attr(name string, value Value) Value {
-	if e, ok := this.value[name]; ok {
-		e.Value = value
-		this.value[name] = e
+	if e, ok := this.value.Get(name); ok {
+		if !this.meta.frozen {
+			e.Value = value
+			this.value.Set(name, e)
+			if this.meta.module != nil {
+				this.meta.module.sync(name, value)
+			}
+		}
+	} else if !this.meta.frozen {
+		this.Create_Var(name, 0, value, false)
 	}
 	return value
 }
 func (this Object) On_get_Variable(name string) (*Variable, bool) {
 	var v Variable
 	var ok bool
-	v, ok = this.value[name]
+	v, ok = this.value.Get(name)
 	return &v, ok
 }
 func (this Object) Create_Var(name string, pos int, value Value, is_const bool) Variable {
-	this.value[name] = Variable{name: name, Pos: pos, is_const: is_const, Value: value}
-	return this.value[name]
+	v := Variable{name: name, Pos: pos, is_const: is_const, Value: value}
+	this.value.Set(name, v)
+	if this.meta.module != nil {
+		this.meta.module.sync(name, value)
+	}
+	return v
 }
-func (this Object) On_in(name Value) Value {
-	if name.VType() == "Node" {
-		if name.(Node).Tp == "var" {
-			_, ok := this.value[name.(Node).Value[0].Re_string("")]
-			return Create_Bool(ok)
+func (this Object) Delete_Var(name string) bool {
+	if this.meta.module != nil {
+		this.meta.module.sync(name, nil)
+	}
+	return this.value.Delete(name)
+}
+func Create_Scope(parent *Object) Object {
+	re := Create_Object(nil).(Object)
+	re.meta.parent = parent
+	return re
+}
+func (this Object) Delete_Scope(pos int) {
+	for _, v := range this.Keys() {
+		if e, _ := this.value.Get(v); e.Pos >= pos {
+			this.Delete_Var(v)
 		}
 	}
+}
+func (this Object) Keys() []string {
+	return this.value.Keys()
+}
+func (this Object) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		VTp   string      `json:"value type"`
+		Value *OrderedMap `json:"value"`
+	}{this.VType(), this.value})
+}
+func (this Object) is_const(name string) bool {
+	v, _ := this.value.Get(name)
+	return v.is_const
+}
+func (this Object) Freeze() {
+	this.meta.frozen = true
+}
+func (this Object) Is_frozen() bool {
+	return this.meta.frozen
+}
+func (this Object) On_in(name Value) Value {
+	switch name.VType() {
+	case "String", "Number":
+		return Create_Bool(this.value.Has(name.Re_string("")))
+	}
 	return Create_Bool(false)
 }
+func (this Object) Re_hash() (string, bool) {
+	return "", false
+}
 
 func (this Array) On_sum(value Value) Value {
 	return Create_Null()
@@ -564,9 +883,17 @@ func (this Array) On_div(value Value) Value {
 func (this Array) On_mul(value Value) Value {
 	return Create_Null()
 }
+
+// Items returns the elements of the array; a zero Array has none.
+func (this Array) Items() []Value {
+	if this.Value == nil {
+		return nil
+	}
+	return *this.Value
+}
 func (this Array) Re_string(prefix string) string {
 	str := "["
-	for i, e := range this.Value {
+	for i, e := range this.Items() {
 		if i > 0 {
 			str += ", "
 		}
@@ -591,21 +918,290 @@ func (this Array) On_call(args []Value, kwargs map[string]*Variable, pos int) (V
 	return Create_Null(), nil
 }
 func (this Array) On_get_attr(name string) Value {
+	if this.Value == nil {
+		// a zero Array is empty, and what its methods add to it is lost
+		this.Value = &[]Value{}
+	}
 	switch name {
+	case "length":
+		return Create_Number(float64(len(this.Items())))
+	case "push":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			*this.Value = append(this.Items(), args...)
+			return Create_Number(float64(len(this.Items()))), nil
+		})
+	case "pop":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			if len(this.Items()) == 0 {
+				return Create_Null(), nil
+			}
+			re := this.Items()[len(this.Items())-1]
+			*this.Value = this.Items()[:len(this.Items())-1]
+			return re, nil
+		})
+	case "shift":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			if len(this.Items()) == 0 {
+				return Create_Null(), nil
+			}
+			re := this.Items()[0]
+			*this.Value = append([]Value{}, this.Items()[1:]...)
+			return re, nil
+		})
+	case "unshift":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			*this.Value = append(append([]Value{}, args...), this.Items()...)
+			return Create_Number(float64(len(this.Items()))), nil
+		})
+	case "slice":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			length := len(this.Items())
+			start, end := 0, length
+			if v, ok := get_arg(args, kwargs, 0, "start"); ok {
+				start = array_index(v, length)
+			}
+			if v, ok := get_arg(args, kwargs, 1, "end"); ok {
+				end = array_index(v, length)
+			}
+			if end < start {
+				end = start
+			}
+			return Create_Array(append([]Value{}, this.Items()[start:end]...)), nil
+		})
+	case "splice":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			length := len(this.Items())
+			start, count := 0, length
+			if v, ok := get_arg(args, kwargs, 0, "start"); ok {
+				start = array_index(v, length)
+			}
+			count = length - start
+			if v, ok := get_arg(args, kwargs, 1, "count"); ok {
+				count = int(v.Re_number())
+				if count < 0 {
+					count = 0
+				} else if start+count > length {
+					count = length - start
+				}
+			}
+			items := []Value{}
+			if len(args) > 2 {
+				items = args[2:]
+			}
+			removed := append([]Value{}, this.Items()[start:start+count]...)
+			re := append([]Value{}, this.Items()[:start]...)
+			re = append(re, items...)
+			*this.Value = append(re, this.Items()[start+count:]...)
+			return Create_Array(removed), nil
+		})
+	case "map":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			function, err := get_callback(args, kwargs, 0, "function")
+			if err != nil {
+				return Create_Null(), err
+			}
+			re := []Value{}
+			for i, v := range this.Items() {
+				r, err := Call(function, []Value{v, Create_Number(float64(i))}, map[string]*Variable{}, pos)
+				if err != nil {
+					return Create_Null(), err
+				}
+				re = append(re, r)
+			}
+			return Create_Array(re), nil
+		})
+	case "filter":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			function, err := get_callback(args, kwargs, 0, "function")
+			if err != nil {
+				return Create_Null(), err
+			}
+			re := []Value{}
+			for i, v := range this.Items() {
+				r, err := Call(function, []Value{v, Create_Number(float64(i))}, map[string]*Variable{}, pos)
+				if err != nil {
+					return Create_Null(), err
+				}
+				if r.Re_bool() {
+					re = append(re, v)
+				}
+			}
+			return Create_Array(re), nil
+		})
+	case "reduce":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			function, err := get_callback(args, kwargs, 0, "function")
+			if err != nil {
+				return Create_Null(), err
+			}
+			items := this.Items()
+			re, ok := get_arg(args, kwargs, 1, "initial")
+			start := 0
+			if !ok {
+				if len(items) == 0 {
+					return Create_Null(), nil
+				}
+				re = items[0]
+				start = 1
+			}
+			for i := start; i < len(items); i++ {
+				re, err = Call(function, []Value{re, items[i], Create_Number(float64(i))}, map[string]*Variable{}, pos)
+				if err != nil {
+					return Create_Null(), err
+				}
+			}
+			return re, nil
+		})
+	case "find":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			function, err := get_callback(args, kwargs, 0, "function")
+			if err != nil {
+				return Create_Null(), err
+			}
+			for i, v := range this.Items() {
+				r, err := Call(function, []Value{v, Create_Number(float64(i))}, map[string]*Variable{}, pos)
+				if err != nil {
+					return Create_Null(), err
+				}
+				if r.Re_bool() {
+					return v, nil
+				}
+			}
+			return Create_Null(), nil
+		})
+	case "index_of":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			value, _ := get_arg(args, kwargs, 0, "value")
+			for i, v := range this.Items() {
+				if Equal(v, value) {
+					return Create_Number(float64(i)), nil
+				}
+			}
+			return Create_Number(-1), nil
+		})
+	case "includes":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			value, _ := get_arg(args, kwargs, 0, "value")
+			return this.On_in(value), nil
+		})
+	case "sort":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			var function Value
+			if _, ok := get_arg(args, kwargs, 0, "function"); ok {
+				f, err := get_callback(args, kwargs, 0, "function")
+				if err != nil {
+					return Create_Null(), err
+				}
+				function = f
+			}
+			var err any
+			sort.SliceStable(this.Items(), func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				a, b := this.Items()[i], this.Items()[j]
+				if function == nil {
+					if a.VType() == "Number" && b.VType() == "Number" {
+						return a.Re_number() < b.Re_number()
+					}
+					return a.Re_string("") < b.Re_string("")
+				}
+				r, e := Call(function, []Value{a, b}, map[string]*Variable{}, pos)
+				if e != nil {
+					err = e
+					return false
+				}
+				return r.Re_number() < 0
+			})
+			if err != nil {
+				return Create_Null(), err
+			}
+			return this, nil
+		})
+	case "reverse":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			items := this.Items()
+			for i := 0; i < len(items)/2; i++ {
+				j := len(items) - i - 1
+				items[i], items[j] = items[j], items[i]
+			}
+			return this, nil
+		})
+	case "join":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			sep := ","
+			if v, ok := get_arg(args, kwargs, 0, "sep"); ok {
+				sep = v.Re_string("")
+			}
+			strs := []string{}
+			for _, v := range this.Items() {
+				strs = append(strs, v.Re_string(""))
+			}
+			return Create_String(strings.Join(strs, sep)), nil
+		})
+	case "concat":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			re := append([]Value{}, this.Items()...)
+			for _, v := range args {
+				if v.VType() == "Array" {
+					re = append(re, v.(Array).Items()...)
+				} else {
+					re = append(re, v)
+				}
+			}
+			return Create_Array(re), nil
+		})
+	case "flat":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			depth := 1
+			if v, ok := get_arg(args, kwargs, 0, "depth"); ok {
+				depth = int(v.Re_number())
+			}
+			return Create_Array(flat_values(this.Items(), depth)), nil
+		})
 	}
 	return Create_Null()
 }
+func (this Array) Attr_Names() []string {
+	return []string{"length", "push", "pop", "shift", "unshift", "slice", "splice", "map", "filter", "reduce", "find", "index_of", "includes", "sort", "reverse", "join", "concat", "flat"}
+}
 func (this Array) On_set_attr(name string, value Value) Value {
 	return Create_Null()
 }
 func (this Array) On_in(name Value) Value {
-	for _, v := range this.Value {
-		if v == name {
+	for _, v := range this.Items() {
+		if Equal(v, name) {
 			return Create_Bool(true)
 		}
 	}
 	return Create_Bool(false)
 }
+func (this Array) Re_hash() (string, bool) {
+	return "", false
+}
+func array_index(value Value, length int) int {
+	i := int(value.Re_number())
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	} else if i > length {
+		return length
+	}
+	return i
+}
+func flat_values(values []Value, depth int) []Value {
+	re := []Value{}
+	for _, v := range values {
+		if v.VType() == "Array" && depth > 0 {
+			re = append(re, flat_values(v.(Array).Items(), depth-1)...)
+		} else {
+			re = append(re, v)
+		}
+	}
+	return re
+}
 
 func (this Pointer) On_sum(value Value) Value {
 	return this.value.Value.On_sum(value)
@@ -643,6 +1239,9 @@ func (this Pointer) On_set_attr(name string, value Value) Value {
 func (this Pointer) On_in(name Value) Value {
 	return this.value.Value.On_in(name)
 }
+func (this Pointer) Re_hash() (string, bool) {
+	return "", false
+}
 
 func Sum(value1 Value, value2 Value) Value {
 	return value1.On_sum(value2)
@@ -687,6 +1286,65 @@ func Set_attr(value Value, name Value, value_set Value) Value {
 func In(value Value, name Value) Value {
 	return value.On_in(name)
 }
+func Hash(value Value) (string, bool) {
+	return value.Re_hash()
+}
+func Equal(value1 Value, value2 Value) bool {
+	if value1 == nil || value2 == nil {
+		return value1 == value2
+	}
+	if value1.VType() != value2.VType() {
+		return false
+	}
+	switch value1.VType() {
+	case "Number":
+		return value1.Re_number() == value2.Re_number()
+	case "String":
+		return value1.Re_string("") == value2.Re_string("")
+	case "Bool":
+		return value1.Re_bool() == value2.Re_bool()
+	case "Null":
+		return true
+	case "Object":
+		o1, _ := to_object(value1)
+		o2, _ := to_object(value2)
+		return o1.value == o2.value
+	case "Tuple":
+		t1, t2 := value1.(Tuple).Value, value2.(Tuple).Value
+		if len(t1) != len(t2) {
+			return false
+		}
+		for i := range t1 {
+			if !Equal(t1[i], t2[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if reflect.TypeOf(value1).Comparable() {
+		return value1 == value2
+	}
+	return false
+}
+func get_arg(args []Value, kwargs map[string]*Variable, index int, name string) (Value, bool) {
+	if len(args) > index {
+		return args[index], true
+	} else if v, ok := kwargs[name]; ok {
+		return v.Value, true
+	}
+	return nil, false
+}
+func get_callback(args []Value, kwargs map[string]*Variable, index int, name string) (Value, any) {
+	v, ok := get_arg(args, kwargs, index, name)
+	if !ok {
+		v = Create_Null()
+	}
+	switch v.VType() {
+	case "Function", "GoFunction", "Pointer":
+		return v, nil
+	}
+	return nil, Error{code: Code_Not_Callable, kind: "erro3", key: "erro msg10", args: []string{v.Re_string("")}}
+}
 
 /*
 	func Get_variable(value Value, name Value) *Value {
@@ -744,25 +1402,36 @@ func Create_Pointer(value *Variable) Value {
 	return re
 }
 func Create_Object(publics map[string]Value) Value {
-	vars := make(map[string]Variable)
+	keys := []string{}
 	for i := range publics {
-		vars[i] = Variable{name: i, Value: publics[i]}
+		keys = append(keys, i)
 	}
-	re := Object{value: vars}
+	sort.Strings(keys)
+	vars := Create_OrderedMap()
+	for _, i := range keys {
+		vars.Set(i, Variable{name: i, Value: publics[i]})
+	}
+	re := Object{value: vars, meta: &object_meta{}}
 	re.VTp = re.VType()
 	return re
 }
 func Create_Array(values []Value) Value {
-	re := Array{Value: values}
+	if values == nil {
+		values = []Value{}
+	}
+	re := Array{Value: &values}
 	re.VTp = re.VType()
 	return re
 }
 
 type Token struct {
-	value Value
-	tp    string
-	line  uint64
-	col   uint64
+	value    Value
+	tp       string
+	line     uint64
+	col      uint64
+	end_line uint64
+	end_col  uint64
+	err      Error
 }
 
 func (this *Token) Re_string() string {
@@ -771,57 +1440,59 @@ func (this *Token) Re_string() string {
 	}
 	return this.tp + ":" + this.value.Re_string("")
 }
+func (this *Token) Type() string {
+	return this.tp
+}
+
+// Value is the name of a "var" token and the value of a literal, or nil.
+func (this *Token) Value() Value {
+	return this.value
+}
+func (this *Token) Span() Span {
+	return Span{Start: Position{Line: this.line, Col: this.col}, End: Position{Line: this.end_line, Col: this.end_col}}
+}
+
+// Err is the error of an "invalid" token, or nil.
+func (this *Token) Err() any {
+	if this.tp != "invalid" {
+		return nil
+	}
+	return this.err
+}
 
 type Error struct {
-	msg         string
+	code        Code
+	severity    Severity
+	kind        string
+	key         string
+	args        []string
+	locale      string
 	other_error any
 	line        uint64
 	col         uint64
+	end_line    uint64
+	end_col     uint64
 	lines       []string
+	traceback   []Call_Frame
 }
 
+// Errors is every syntax error found by Parser.Parse, in source order.
+type Errors []Error
+
 //export lexer
 type Lexer struct {
-	tok  int
-	txt  string
-	char string
-	line uint64
-	col  uint64
+	tok   int
+	txt   string
+	runes []rune
+	char  string
+	line  uint64
+	col   uint64
 }
 type Cache struct {
 	Txt   string  `json:"txt"`
 	Nodes []Value `json:"nodes"`
 }
 
-func lang_text(txt string, extras []string) string {
-	switch lang {
-	case "pt-br":
-		switch txt {
-		case "erro1":
-			return "Erro de Syntaxe: "
-		case "erro2":
-			return "Erro de Variavel: "
-		case "erro msg1":
-			return "no numero possui mais de 1 ponto final"
-		case "erro msg2":
-			return "o simbolo " + extras[0] + " não existe nessa linguagem"
-		case "erro msg3":
-			return "vc colocol em uma posição errada"
-		case "erro msg4":
-			return "vc esqueceu de fechar uma string"
-		case "erro msg5":
-			return "A variavel '" + extras[0] + "' não existe"
-		case "erro msg6":
-			return "expresão invalida"
-		case "erro msg7":
-			return "vc esqueceu de fechar as chaves"
-		case "erro msg8":
-			return "vc esqueceu de fechar os parentses"
-		}
-	}
-	return ""
-}
-
 var varsName = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_"
 
 func (this *Lexer) is_next(txt string) bool {
@@ -829,6 +1500,7 @@ func (this *Lexer) is_next(txt string) bool {
 	walk := 1
 	spaces := 0
 	v := this.char
+	tok, line, col, char := this.tok, this.line, this.col, this.char
 	(*Lexer).next(this)
 	for this.char != "" {
 		if walk-spaces >= len(this.char)+1 {
@@ -849,21 +1521,15 @@ func (this *Lexer) is_next(txt string) bool {
 		walk++
 	}
 	if !ok {
-		this.Return(walk)
+		this.tok, this.line, this.col, this.char = tok, line, col, char
 	}
 	return ok
 }
 func (this *Lexer) Return(number int) {
-	i := 0
-	for i < number {
-		this.tok -= 1
-		this.col -= 1
-		(*Lexer).load(this)
-		if this.char == "\n" {
-			this.line--
-			this.col = uint64(len(strings.Split(this.txt, "\n")[this.line-1]))
-		}
-		i++
+	target := this.tok - number
+	this.tok, this.line, this.col = -1, 1, 0
+	for this.tok < target {
+		(*Lexer).next(this)
 	}
 }
 func To_int(n string) Value {
@@ -873,37 +1539,70 @@ func To_int(n string) Value {
 func (this *Lexer) Tokenizer(txt string) ([]Token, any) {
 	this.tok = -1
 	this.txt = txt
+	this.runes = []rune(txt)
 	this.char = ""
 	this.line = 1
 	this.col = 0
 	(*Lexer).next(this)
 	var re []Token
+	ended := 0
 	for this.char != "" {
+		for ; ended < len(re); ended++ {
+			re[ended].end_line, re[ended].end_col = this.line, this.col
+		}
+		if this.char == "." && this.peek(3) == "..." {
+			re = append(re, Token{tp: "...", col: this.col, line: this.line})
+			(*Lexer).next(this)
+			(*Lexer).next(this)
+			(*Lexer).next(this)
+			continue
+		}
+		if this.char == "." && this.peek(2) == ".." {
+			tp := ".."
+			if this.peek(3) == "..<" {
+				tp = "..<"
+			}
+			re = append(re, Token{tp: tp, col: this.col, line: this.line})
+			for range tp {
+				(*Lexer).next(this)
+			}
+			continue
+		}
 		if strings.Contains("0123456789.", this.char) {
 			var n string = this.char
+			col, line := this.col, this.line
 			ok := false
 			if this.char == "." {
 				ok = true
 			}
+			var bad *Token
 			(*Lexer).next(this)
 			for this.char != "" && strings.Contains("0123456789.", this.char) {
+				if this.char == "." && this.peek(2) == ".." {
+					break
+				}
 				if this.char == "." {
-					if ok {
-						return []Token{}, Error{msg: lang_text("erro1", []string{}) + lang_text("erro msg1", []string{}), lines: strings.Split(this.txt, "\n"), line: this.line, col: this.col}
+					if ok && bad == nil {
+						t := this.invalid(Code_Invalid_Number, "erro msg1", nil, this.line, this.col)
+						bad = &t
 					}
 					ok = true
 				}
 				n += this.char
 				(*Lexer).next(this)
 			}
+			if bad != nil {
+				re = append(re, *bad)
+				continue
+			}
 			if n == "." || strings.HasSuffix(n, ".") {
 				if strings.HasSuffix(n, ".") && !(n == ".") {
-					re = append(re, Token{value: To_int(n), tp: "value", col: this.col, line: this.line})
+					re = append(re, Token{value: To_int(n), tp: "value", col: col, line: line})
 				}
-				re = append(re, Token{tp: ".", col: this.col, line: this.line})
+				re = append(re, Token{tp: ".", col: this.col - 1, line: this.line})
 				continue
 			}
-			re = append(re, Token{value: To_int(n), tp: "value", col: this.col, line: this.line})
+			re = append(re, Token{value: To_int(n), tp: "value", col: col, line: line})
 			continue
 		} else if strings.Contains(varsName, this.char) {
 			var n string = this.char
@@ -913,6 +1612,10 @@ func (this *Lexer) Tokenizer(txt string) ([]Token, any) {
 				n += this.char
 				(*Lexer).next(this)
 			}
+			if len(re) > 0 && re[len(re)-1].tp == "." {
+				re = append(re, Token{tp: "var", value: Create_String(n), col: col, line: line})
+				continue
+			}
 			switch n {
 			case "global":
 				re = append(re, Token{tp: "create global", col: col, line: line})
@@ -924,10 +1627,10 @@ func (this *Lexer) Tokenizer(txt string) ([]Token, any) {
 				re = append(re, Token{tp: "create local", col: col, line: line})
 				break
 			case "and":
-				re = append(re, Token{tp: "&&", col: this.col, line: this.line})
+				re = append(re, Token{tp: "&&", col: col, line: line})
 				break
 			case "or":
-				re = append(re, Token{tp: "||", col: this.col, line: this.line})
+				re = append(re, Token{tp: "||", col: col, line: line})
 				break
 			case "var":
 				re = append(re, Token{tp: "create local", col: col, line: line})
@@ -940,6 +1643,15 @@ func (this *Lexer) Tokenizer(txt string) ([]Token, any) {
 			case "exist":
 				re = append(re, Token{tp: "exist", col: col, line: line})
 				break
+			case "in":
+				re = append(re, Token{tp: "in", col: col, line: line})
+				break
+			case "for":
+				re = append(re, Token{tp: "for", col: col, line: line})
+				break
+			case "yield":
+				re = append(re, Token{tp: "yield", col: col, line: line})
+				break
 			default:
 				re = append(re, Token{tp: "var", value: Create_String(n), col: col, line: line})
 				break
@@ -948,58 +1660,67 @@ func (this *Lexer) Tokenizer(txt string) ([]Token, any) {
 		} else {
 			switch this.char {
 			case "+":
-				re = append(re, Token{tp: "+", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: "+", col: this.col, line: this.line})
 				break
 			case "-":
-				re = append(re, Token{tp: "-", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: "-", col: this.col, line: this.line})
 				break
 			case "*":
-				re = append(re, Token{tp: "*", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: "*", col: this.col, line: this.line})
 				break
 			case "/":
-				re = append(re, Token{tp: "/", col: this.col - 1, line: this.line})
+				if this.peek(2) == "//" {
+					re = append(re, this.comment())
+					continue
+				}
+				re = append(re, Token{tp: "/", col: this.col, line: this.line})
 				break
 			case "&":
-				if this.is_next("&&") {
-					re = append(re, Token{tp: "&&", col: this.col, line: this.line})
+				if col := this.col; this.is_next("&&") {
+					re = append(re, Token{tp: "&&", col: col, line: this.line})
 				} else {
-					return []Token{}, Error{msg: lang_text("erro1", []string{}) + lang_text("erro msg2", []string{this.char}), line: this.line, col: this.col, lines: strings.Split(this.txt, "\n")}
+					re = append(re, this.invalid(Code_Unknown_Symbol, "erro msg2", []string{this.char}, this.line, this.col))
 				}
 				break
 			case "|":
-				if this.is_next("||") {
-					re = append(re, Token{tp: "||", col: this.col, line: this.line})
+				if col := this.col; this.is_next("||") {
+					re = append(re, Token{tp: "||", col: col, line: this.line})
 				} else {
-					return []Token{}, Error{msg: lang_text("erro1", []string{}) + lang_text("erro msg2", []string{this.char}), line: this.line, col: this.col, lines: strings.Split(this.txt, "\n")}
+					re = append(re, this.invalid(Code_Unknown_Symbol, "erro msg2", []string{this.char}, this.line, this.col))
 				}
 			case "=":
-				if this.is_next("==") {
-					re = append(re, Token{tp: "==", col: this.col - 1, line: this.line})
+				if col := this.col; this.is_next("==") {
+					re = append(re, Token{tp: "==", col: col, line: this.line})
 				} else {
-					re = append(re, Token{tp: "=", col: this.col - 1, line: this.line})
+					re = append(re, Token{tp: "=", col: this.col, line: this.line})
 				}
 				break
 			case "(":
-				re = append(re, Token{tp: "(", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: "(", col: this.col, line: this.line})
 				break
 			case ")":
-				re = append(re, Token{tp: ")", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: ")", col: this.col, line: this.line})
 				break
 			case "{":
-				re = append(re, Token{tp: "{", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: "{", col: this.col, line: this.line})
+				break
+			case "[":
+				re = append(re, Token{tp: "[", col: this.col, line: this.line})
+				break
+			case "]":
+				re = append(re, Token{tp: "]", col: this.col, line: this.line})
 				break
 			case "}":
-				re = append(re, Token{tp: "}", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: "}", col: this.col, line: this.line})
 				break
 			case ",":
-				re = append(re, Token{tp: ",", col: this.col - 1, line: this.line})
+				re = append(re, Token{tp: ",", col: this.col, line: this.line})
 				break
 			case string('"'):
 				col := this.col
 				line := this.line
 				(*Lexer).next(this)
-				v := this.char
-				(*Lexer).next(this)
+				v := ""
 				ok := true
 				for this.char != "" {
 					if this.char == string('"') {
@@ -1010,9 +1731,10 @@ func (this *Lexer) Tokenizer(txt string) ([]Token, any) {
 					(*Lexer).next(this)
 				}
 				if ok {
-					return []Token{}, Error{msg: lang_text("erro1", nil) + lang_text("erro msg4", nil), line: line, col: col, lines: strings.Split(this.txt, "\n")}
+					re = append(re, this.invalid(Code_Unterminated_String, "erro msg4", nil, line, col))
+					break
 				}
-				re = append(re, Token{tp: "value", value: Create_String(v), col: this.col, line: this.line})
+				re = append(re, Token{tp: "value", value: Create_String(v), col: col, line: line})
 				break
 			case ";":
 				re = append(re, Token{tp: "split", col: this.col, line: this.line})
@@ -1025,26 +1747,61 @@ func (this *Lexer) Tokenizer(txt string) ([]Token, any) {
 				re = append(re, Token{tp: "new line", col: this.col, line: this.line})
 				break
 			default:
-				return []Token{}, Error{msg: lang_text("erro1", []string{}) + lang_text("erro msg2", []string{this.char}), line: this.line, col: this.col, lines: strings.Split(this.txt, "\n")}
+				re = append(re, this.invalid(Code_Unknown_Symbol, "erro msg2", []string{this.char}, this.line, this.col))
 			}
 		}
 		(*Lexer).next(this)
 	}
+	for ; ended < len(re); ended++ {
+		re[ended].end_line, re[ended].end_col = this.line, this.col+1
+	}
 	return re, nil
 }
+
+// comment reads a comment, from // to the end of the line. The parser
+// skips comments; they are kept as tokens for the tools that print or
+// read them, like the formatter and the lint:ignore comments.
+func (this *Lexer) comment() Token {
+	re := Token{tp: "comment", col: this.col, line: this.line}
+	txt := ""
+	for this.char != "" && this.char != "\n" {
+		txt += this.char
+		(*Lexer).next(this)
+	}
+	re.value = Create_String(strings.TrimRight(txt, " \t"))
+	return re
+}
+
+// invalid is the token for text that is not part of the language. The
+// parser reports its error when it reaches it, so the rest of the text is
+// still read.
+func (this *Lexer) invalid(code Code, key string, args []string, line uint64, col uint64) Token {
+	return Token{tp: "invalid", err: Error{code: code, kind: "erro1", key: key, args: args, line: line, col: col, lines: strings.Split(this.txt, "\n")}, line: line, col: col}
+}
+func (this *Lexer) peek(n int) string {
+	end := this.tok + n
+	if end > len(this.runes) {
+		end = len(this.runes)
+	}
+	if this.tok < 0 || this.tok >= end {
+		return ""
+	}
+	return string(this.runes[this.tok:end])
+}
 func (this *Lexer) next() {
 	this.tok++
 	(*Lexer).load(this)
 }
 func (this *Lexer) load() {
-	if this.tok >= len(this.txt) {
+	if this.tok >= len(this.runes) {
 		this.char = ""
 	} else {
-		this.char = string([]rune(this.txt)[this.tok])
-		this.col++
-		if this.char == "\n" {
+		this.char = string(this.runes[this.tok])
+		if this.tok > 0 && this.runes[this.tok-1] == '\n' {
 			this.line++
 			this.col = 1
+		} else {
+			this.col++
 		}
 	}
 }
@@ -1053,6 +1810,7 @@ func splitTokens(toks []Token, st string, vspt string, vept string) ([][]Token,
 	var ts []Token = toks
 	i := 0
 	v := 1
+	inner := 0
 	walk := 0
 	var re [][]Token
 	for true {
@@ -1063,12 +1821,16 @@ func splitTokens(toks []Token, st string, vspt string, vept string) ([][]Token,
 			v++
 		} else if ts[i].tp == vept {
 			v--
+		} else if strings.Contains("([{", ts[i].tp) {
+			inner++
+		} else if strings.Contains(")]}", ts[i].tp) {
+			inner--
 		}
 		if v < 1 {
 			re = append(re, ts[:i])
 			break
 		}
-		if ts[i].tp == st {
+		if ts[i].tp == st && v == 1 && inner == 0 {
 			re = append(re, ts[:i])
 			ts = ts[i+1:]
 			i = -1
@@ -1087,6 +1849,13 @@ type Parser struct {
 	tok     Token
 	Lexer   Lexer
 	txt     string
+	parens  int
+	errors  Errors
+	// generator is true in the body of a function*, the only place yield
+	// is allowed; the body of a function nested in it resets it.
+	generator bool
+	// Locale is the language of the messages of the syntax errors.
+	Locale string
 }
 
 func (this *Parser) next_tok() {
@@ -1109,6 +1878,15 @@ func (this *Parser) load_tok() {
 		this.tok = this.codes[this.code][this.tok_pos]
 	}
 }
+func without_comments(tokens []Token) []Token {
+	re := tokens[:0:0]
+	for _, t := range tokens {
+		if t.tp != "comment" {
+			re = append(re, t)
+		}
+	}
+	return re
+}
 func is_end_code(tok Token) bool {
 	return tok.tp == "end code"
 }
@@ -1133,9 +1911,15 @@ func (this *Parser) Make_Nodes_Toks(toks [][]Token, end string, ok bool) ([]Valu
 	var err any
 	for this.code < uint64(len(this.codes)) {
 		if ok {
-			result, err = (*Parser).new_line(this)
+			result = this.statement()
 		} else {
 			result, err = (*Parser).expr(this)
+			for err == nil && this.tok.tp == "new line" {
+				(*Parser).next_tok(this)
+			}
+			if err == nil && !is_end_code(this.tok) {
+				err = this.unexpected()
+			}
 		}
 		if err != nil {
 			return []Value{}, err
@@ -1157,15 +1941,15 @@ func (this *Parser) Make_Nodes(txt string) ([]Value, any) {
 	if err != nil {
 		return []Value{}, err
 	}
+	tokens = without_comments(tokens)
 	this.code = 0
 	this.codes = [][]Token{tokens}
+	this.errors = nil
+	this.generator = false
 	var re []Value
 	(*Parser).load_code(this)
 	for this.code < uint64(len(this.codes)) {
-		result, err := (*Parser).new_line(this)
-		if err != nil {
-			return []Value{}, err
-		}
+		result := this.statement()
 		if result == nil {
 			(*Parser).next_code(this)
 			continue
@@ -1175,8 +1959,98 @@ func (this *Parser) Make_Nodes(txt string) ([]Value, any) {
 		}
 		(*Parser).next_code(this)
 	}
+	if len(this.errors) > 0 {
+		sort.SliceStable(this.errors, func(i, j int) bool {
+			a, b := this.errors[i], this.errors[j]
+			return a.line < b.line || a.line == b.line && a.col < b.col
+		})
+		return re, localize(this.errors, this.Locale)
+	}
 	return re, nil
 }
+
+// statement parses the statement at the start of the current code. When
+// it has a syntax error, the error is kept in this.errors and the code is
+// split after the statement, so the next ones are still parsed and every
+// error of the text is reported by one Parse. The statement ends at the
+// first line break after the error outside of brackets, or before a line
+// starting with a declaration when the only brackets left open were opened
+// before the error, which may never be closed.
+func (this *Parser) statement() Value {
+	codes, code, parens := this.codes, this.code, this.parens
+	result, err := (*Parser).new_line(this)
+	if err == nil {
+		return result
+	}
+	e, _ := err.(Error)
+	this.errors = append(this.errors, e)
+	this.codes, this.code, this.parens = codes, code, parens
+	toks := codes[code]
+	// after counts the brackets opened after the error that are still open
+	i, depth, after := 0, 0, 0
+	for ; i < len(toks); i++ {
+		past := toks[i].line > e.line || toks[i].line == e.line && toks[i].col >= e.col
+		switch toks[i].tp {
+		case "(", "[", "{":
+			depth++
+			if toks[i].line > e.line || toks[i].line == e.line && toks[i].col > e.col {
+				after++
+			}
+			continue
+		case ")", "]":
+			if depth > 0 {
+				depth--
+			}
+			if after > 0 {
+				after--
+			}
+			continue
+		case "}":
+			if after > 0 {
+				after--
+			}
+			if depth > 0 {
+				depth--
+				continue
+			}
+		case "new line", "split":
+			if depth > 0 && (after > 0 || i+1 == len(toks) || !starts_statement(toks[i+1])) {
+				continue
+			}
+		default:
+			continue
+		}
+		if past {
+			break
+		}
+	}
+	if i < len(toks) {
+		i++
+	}
+	this.codes = sum_codes([][][]Token{codes[:code], {toks[:i]}, {toks[i:]}, codes[code+1:]})
+	return nil
+}
+
+// starts_statement is true for the tokens that only begin a statement, so
+// a line starting with one can't be the continuation of an expression.
+func starts_statement(tok Token) bool {
+	switch tok.tp {
+	case "create local", "create global", "if", "for", "return":
+		return true
+	}
+	return false
+}
+
+// unexpected is the error for a token that can not start or continue an
+// expression. Invalid tokens carry the message of the lexer.
+func (this *Parser) unexpected() Error {
+	re := Error{code: Code_Unexpected_Token, kind: "erro1", key: "erro msg3", line: this.tok.line, col: this.tok.col, lines: strings.Split(this.txt, "\n")}
+	if this.tok.tp == "invalid" {
+		re = this.tok.err
+	}
+	re.end_line, re.end_col = this.tok.end_line, this.tok.end_col
+	return re
+}
 func (this *Parser) WriteCache(txt string, cache any) ([]Value, any) {
 	file, _ := os.Create(cache.(string))
 	nodes, err := this.Make_Nodes(txt)
@@ -1230,9 +2104,12 @@ func (this *Parser) new_line() (Value, any) {
 		return nil, err
 	}
 	switch this.tok.tp {
-	case "new line":
+	case "new line", "split":
 		(*Parser).next_tok(this)
-		this.codes = sum_codes([][][]Token{{this.codes[this.code][:this.tok_pos]}, {this.codes[this.code][this.tok_pos:]}})
+		this.codes = sum_codes([][][]Token{this.codes[:this.code], {this.codes[this.code][:this.tok_pos]}, {this.codes[this.code][this.tok_pos:]}, this.codes[this.code+1:]})
+	case "end code":
+	default:
+		return nil, this.unexpected()
 	}
 	return re, err
 }
@@ -1250,6 +2127,9 @@ func (this *Parser) expr() (Value, any) {
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "=", tok.line, tok.col)
 		break
 	case "&&":
@@ -1259,6 +2139,9 @@ func (this *Parser) expr() (Value, any) {
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "&&", tok.line, tok.col)
 		break
 	case "||":
@@ -1268,16 +2151,22 @@ func (this *Parser) expr() (Value, any) {
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "||", tok.line, tok.col)
 		break
 	case ")":
+		if this.parens > 0 {
+			break
+		}
 		(*Parser).next_tok(this)
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg8", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Unclosed_Paren, kind: "erro1", key: "erro msg8", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 	}
 	return re, err
 }
 func (this *Parser) booleans() (Value, any) {
-	re, err := (*Parser).calc(this)
+	re, err := (*Parser).range_expr(this)
 	tok := this.tok
 	if err != nil {
 		return nil, err
@@ -1290,28 +2179,82 @@ func (this *Parser) booleans() (Value, any) {
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "==", tok.line, tok.col)
 		break
+	case "in":
+		var n Value
+		(*Parser).next_tok(this)
+		if is_end_code(this.tok) {
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		}
+		n, err = (*Parser).booleans(this)
+		if err != nil {
+			return nil, err
+		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
+		re = Create_Node([]Value{re, n}, "in", tok.line, tok.col)
+		break
 	}
 	return re, err
 }
-func (this *Parser) call() (Value, any) {
-	re, err := (*Parser).term(this)
+func (this *Parser) range_expr() (Value, any) {
+	re, err := (*Parser).calc(this)
 	tok := this.tok
 	if err != nil {
 		return nil, err
 	}
 	switch this.tok.tp {
-	case "(":
-		v, err := (*Parser).Param(this)
+	case "..", "..<":
+		(*Parser).next_tok(this)
+		if is_end_code(this.tok) {
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		}
+		n, err := (*Parser).calc(this)
 		if err != nil {
 			return nil, err
 		}
-		re = Create_Node([]Value{re, v}, "call", tok.line, tok.col)
-		break
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
+		re = Create_Node([]Value{re, n, Create_Bool(tok.tp == "..")}, "range", tok.line, tok.col)
 	}
 	return re, err
 }
+func (this *Parser) call() (Value, any) {
+	re, err := (*Parser).term(this)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := this.tok
+		switch this.tok.tp {
+		case "(":
+			v, err := (*Parser).Param(this)
+			if err != nil {
+				return nil, err
+			}
+			re = Create_Node([]Value{re, v}, "call", tok.line, tok.col)
+			continue
+		case ".":
+			if re.(Node).Tp != "call" {
+				return re, err
+			}
+			(*Parser).next_tok(this)
+			v, err := (*Parser).term(this)
+			if err != nil {
+				return nil, err
+			}
+			re = Create_Node([]Value{re, v}, "get attr", tok.line, tok.col)
+			continue
+		}
+		return re, err
+	}
+}
 func (this *Parser) calc() (Value, any) {
 	re, err := (*Parser).call(this)
 	tok := this.tok
@@ -1323,48 +2266,60 @@ func (this *Parser) calc() (Value, any) {
 		var n Value
 		(*Parser).next_tok(this)
 		if is_end_code(this.tok) {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		n, err = (*Parser).calc(this)
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "+", tok.line, tok.col)
 		break
 	case "-":
 		var n Value
 		(*Parser).next_tok(this)
 		if is_end_code(this.tok) {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		n, err = (*Parser).calc(this)
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "-", tok.line, tok.col)
 		break
 	case "*":
 		var n Value
 		(*Parser).next_tok(this)
 		if is_end_code(this.tok) {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		n, err = (*Parser).calc(this)
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "*", tok.line, tok.col)
 		break
 	case "/":
 		var n Value
 		(*Parser).next_tok(this)
 		if is_end_code(this.tok) {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		n, err = (*Parser).calc(this)
 		if err != nil {
 			return nil, err
 		}
+		if err := this.missing_operand(n, tok); err != nil {
+			return nil, err
+		}
 		re = Create_Node([]Value{re, n}, "/", tok.line, tok.col)
 		break
 	}
@@ -1385,6 +2340,17 @@ func (this *Parser) calc() (Value, any) {
 		return re
 	}
 */
+
+// missing_operand is the error of the operator tok when its right side n
+// is empty, as in 1 + at the end of the code, or a statement, as in 1 +
+// followed by a line declaring a variable.
+func (this *Parser) missing_operand(n Value, tok Token) any {
+	switch n.(Node).Tp {
+	case "null", "create local", "create global", "if", "for", "return":
+		return Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+	}
+	return nil
+}
 func (this *Parser) term() (Value, any) {
 	re, err := (*Parser).factor(this)
 	tok := this.tok
@@ -1415,15 +2381,21 @@ func (this *Parser) factor() (Value, any) {
 	case "-":
 		(*Parser).next_tok(this)
 		n, err := this.factor()
+		if err != nil {
+			return nil, err
+		}
 		if n.(Node).Tp == "null" {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		return Create_Node([]Value{n}, "inverse number", tok.line, tok.col), err
 	case "if":
 		(*Parser).next_tok(this)
 		n, err := this.expr()
+		if err != nil {
+			return nil, err
+		}
 		if n.(Node).Tp == "null" {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		var n2 Value
 		n2, err = this.Enter_Code()
@@ -1441,6 +2413,11 @@ func (this *Parser) factor() (Value, any) {
 		return Create_Node([]Value{n}, "create local", tok.line, tok.col), err
 	case "function":
 		(*Parser).next_tok(this)
+		tp := "function"
+		if this.tok.tp == "*" {
+			tp = "generator"
+			(*Parser).next_tok(this)
+		}
 		vn := ""
 		if this.tok.tp == "var" {
 			vn = this.tok.value.Re_string("")
@@ -1450,20 +2427,39 @@ func (this *Parser) factor() (Value, any) {
 		if err != nil {
 			return nil, err
 		}
+		generator := this.generator
+		this.generator = tp == "generator"
 		code, err := (*Parser).Enter_Code(this)
+		this.generator = generator
 		if err != nil {
 			return nil, err
 		}
-		return Create_Node([]Value{Create_String(vn), parameters, code}, "function", tok.line, tok.col), nil
+		return Create_Node([]Value{Create_String(vn), parameters, code}, tp, tok.line, tok.col), nil
 	case "return":
 		(*Parser).next_tok(this)
 		n, err := this.expr()
 		return Create_Node([]Value{n}, "return", tok.line, tok.col), err
+	case "yield":
+		if !this.generator {
+			return nil, Error{code: Code_Yield_Outside_Generator, kind: "erro1", key: "erro msg14", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		}
+		(*Parser).next_tok(this)
+		n, err := this.expr()
+		if err != nil {
+			return nil, err
+		}
+		if n.(Node).Tp == "null" {
+			n = Create_Node([]Value{Create_Null()}, "value", tok.line, tok.col)
+		}
+		return Create_Node([]Value{n}, "yield", tok.line, tok.col), nil
 	case "exist":
 		(*Parser).next_tok(this)
 		n, err := this.factor()
+		if err != nil {
+			return nil, err
+		}
 		if n.(Node).Tp != "var" {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		return Create_Node([]Value{n}, "exist", tok.line, tok.col), err
 	case "(":
@@ -1472,23 +2468,68 @@ func (this *Parser) factor() (Value, any) {
 			(*Parser).next_tok(this)
 			return Create_Node([]Value{Create_Null()}, "value", tok.line, tok.col), nil
 		}
+		this.parens++
 		code, err := (*Parser).expr(this)
+		this.parens--
 		if err != nil {
 			return nil, err
 		}
 		if this.tok.tp == ")" {
 			(*Parser).next_tok(this)
 		} else {
-			return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg8", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+			return nil, Error{code: Code_Unclosed_Paren, kind: "erro1", key: "erro msg8", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 		}
 		return Create_Node([]Value{code}, "()", tok.line, tok.col), nil
+	case "[":
+		return (*Parser).Array_Literal(this)
+	case "...":
+		(*Parser).next_tok(this)
+		n, err := this.expr()
+		if err != nil {
+			return nil, err
+		}
+		if n.(Node).Tp == "null" {
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		}
+		return Create_Node([]Value{n}, "spread", tok.line, tok.col), nil
+	case "for":
+		(*Parser).next_tok(this)
+		var target Value
+		var err any
+		switch this.tok.tp {
+		case "[":
+			target, err = this.Array_Literal()
+		case "var":
+			target, err = this.factor()
+		default:
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if this.tok.tp != "in" {
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: this.tok.line, col: this.tok.col, lines: strings.Split(this.txt, "\n")}
+		}
+		(*Parser).next_tok(this)
+		n, err := this.expr()
+		if err != nil {
+			return nil, err
+		}
+		if n.(Node).Tp == "null" {
+			return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		}
+		code, err := this.Enter_Code()
+		if err != nil {
+			return nil, err
+		}
+		return Create_Node([]Value{target, n, code}, "for", tok.line, tok.col), nil
 	case "pointer":
 		(*Parser).next_tok(this)
 		n, err := this.expr()
 		return Create_Node([]Value{n}, "pointer", tok.line, tok.col), err
 	case "+":
 		(*Parser).next_tok(this)
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 	case "*":
 		(*Parser).next_tok(this)
 		/*
@@ -1496,21 +2537,22 @@ func (this *Parser) factor() (Value, any) {
 			if n.(Node).Tp != "var" {
 			}
 		return Create_Node([]Value{n}, "*f", tok.line, tok.col), err*/
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 	case "/":
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 	case "&&":
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 	case "||":
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
-	case "==":
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg6", nil), line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
+	case "==", "in", "..", "..<":
+		return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: tok.line, col: tok.col, lines: strings.Split(this.txt, "\n")}
 	case "new line":
 		(*Parser).next_tok(this)
 		return this.factor()
-	default:
+	case "end code", "split":
 		return Create_Node([]Value{}, "null", tok.line, tok.col), nil
-		//return Create_Node(nil, ""), Error{msg: lang_text("erro1", []string{}) + lang_text("erro msg3", []string{}), line: this.tok.line, col: this.tok.col, lines: strings.Split(this.txt, "\n")}
+	default:
+		return nil, this.unexpected()
 	}
 }
 func (this *Parser) Param() (Value, any) {
@@ -1518,7 +2560,7 @@ func (this *Parser) Param() (Value, any) {
 	if this.tok.tp == "(" {
 		(*Parser).next_tok(this)
 	} else {
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg8", nil), line: line, col: col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Unclosed_Paren, kind: "erro1", key: "erro msg8", line: line, col: col, lines: strings.Split(this.txt, "\n")}
 	}
 	sp, walk := splitTokens(this.codes[this.code][this.tok_pos:], ",", "(", ")")
 	i := 0
@@ -1540,16 +2582,47 @@ func (this *Parser) Param() (Value, any) {
 	if this.tok.tp == ")" {
 		(*Parser).next_tok(this)
 	} else {
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg8", nil), line: line, col: col - 1, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Unclosed_Paren, kind: "erro1", key: "erro msg8", line: line, col: col - 1, lines: strings.Split(this.txt, "\n")}
 	}
 	return Create_Node(v, "Parameters", line, col), nil
 }
+func (this *Parser) Array_Literal() (Value, any) {
+	line, col := this.tok.line, this.tok.col
+	if this.tok.tp == "[" {
+		(*Parser).next_tok(this)
+	} else {
+		return nil, Error{code: Code_Unclosed_Bracket, kind: "erro1", key: "erro msg9", line: line, col: col, lines: strings.Split(this.txt, "\n")}
+	}
+	sp, walk := splitTokens(this.codes[this.code][this.tok_pos:], ",", "[", "]")
+	i := 0
+	for i < walk {
+		(*Parser).next_tok(this)
+		i++
+	}
+	old_codes := this.codes
+	old_tok_pos := this.tok_pos
+	old_code := this.code
+	v, err := this.Make_Nodes_Toks(sp, "", false)
+	if err != nil {
+		return nil, err
+	}
+	this.codes = old_codes
+	this.code = old_code
+	this.tok_pos = old_tok_pos
+	this.load_tok()
+	if this.tok.tp == "]" {
+		(*Parser).next_tok(this)
+	} else {
+		return nil, Error{code: Code_Unclosed_Bracket, kind: "erro1", key: "erro msg9", line: line, col: col, lines: strings.Split(this.txt, "\n")}
+	}
+	return Create_Node(v, "array", line, col), nil
+}
 func (this *Parser) Enter_Code() (Value, any) {
 	line, col := this.tok.line, this.tok.col
 	if this.tok.tp == "{" {
 		(*Parser).next_tok(this)
 	} else {
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg7", nil), line: line, col: col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Unclosed_Brace, kind: "erro1", key: "erro msg7", line: line, col: col, lines: strings.Split(this.txt, "\n")}
 	}
 	i := 0
 	vw := 1
@@ -1587,7 +2660,7 @@ func (this *Parser) Enter_Code() (Value, any) {
 	if this.tok.tp == "}" {
 		(*Parser).next_tok(this)
 	} else {
-		return nil, Error{msg: lang_text("erro1", nil) + lang_text("erro msg7", nil), line: line, col: col, lines: strings.Split(this.txt, "\n")}
+		return nil, Error{code: Code_Unclosed_Brace, kind: "erro1", key: "erro msg7", line: line, col: col, lines: strings.Split(this.txt, "\n")}
 	}
 	return Create_Node(v, "{}", line, col), nil
 }
@@ -1602,9 +2675,32 @@ type Interpreter struct {
 	Globals *Object
 	parser  Parser
 	Debug   bool
+	// Use_VM runs scripts on the bytecode VM instead of walking the nodes.
+	Use_VM bool
+	// Optimize folds constant expressions and drops dead if blocks before
+	// running a script.
+	Optimize bool
+	// Locale is the language of the error messages, "pt-br", "en" or one
+	// added with Register_Messages. Default_Locale is used when empty.
+	Locale string
+	// Args are the command line arguments given to the script, after its
+	// path, and process.args when Process is nil.
+	Args []string
+	// Process is the process scripts see through the process global, the
+	// real one when nil. No_Process leaves the global out.
+	Process    Process
+	No_Process bool
+	// module is the path of the script being run, calls the functions
+	// running in it, for tracebacks.
+	module   string
+	calls    []call
+	entering bool
 }
 
-func (this *Interpreter) Eval(txt string, locals *Object) Value {
+// Eval runs txt with the variables of locals and returns the value of its
+// last statement, or the syntax errors of txt or the error it raised.
+func (this *Interpreter) Eval(txt string, locals *Object) (Value, error) {
+	this.parser.Locale = this.Locale
 	nodes, err := this.parser.Parse(txt)
 	if this.Debug {
 		println("{")
@@ -1614,28 +2710,17 @@ func (this *Interpreter) Eval(txt string, locals *Object) Value {
 		println("}")
 		fmt.Println(err)
 	}
-	isTry := false
-	if livre(err, nil) {
-		i := uint64(0)
-		for i < uint64(len(nodes)) {
-			re, err := (*Interpreter).exec_node(this, nodes[i], locals, 0)
-			if err != nil {
-				e := err.(Error)
-				e.lines = strings.Split(txt, "\n")
-				err = e
-			}
-			if !isTry && !livre(err, nil) {
-				return Create_Null()
-			}
-			if i == uint64(len(nodes)-1) {
-				return re
-			}
-			i++
-		}
+	if err := to_error(err); err != nil {
+		return Create_Null(), err
 	}
-	return Create_Null()
+	re, err2 := this.run(nodes, locals)
+	if err2 != nil {
+		return Create_Null(), to_error(this.with_lines(err2, txt))
+	}
+	return re, nil
 }
 func (this *Interpreter) Exec(txt string, locals *Object) any {
+	this.parser.Locale = this.Locale
 	nodes, err := this.parser.Parse(txt)
 	if this.Debug {
 		println("{")
@@ -1644,21 +2729,92 @@ func (this *Interpreter) Exec(txt string, locals *Object) any {
 		}
 		println("}")
 	}
-	if livre(err, nil) {
-		i := uint64(0)
-		for i < uint64(len(nodes)) {
-			_, err := (*Interpreter).exec_node(this, nodes[i], locals, 0)
-			if err != nil {
-				e := err.(Error)
-				e.lines = strings.Split(txt, "\n")
-				err = e
-				return err
-			}
-			i++
-		}
+	if err := to_error(err); err != nil {
+		return err
+	}
+	_, err = this.run(nodes, locals)
+	if err != nil {
+		return this.with_lines(err, txt)
 	}
 	return nil
 }
+
+// Check finds the errors of txt without running it: the syntax errors or,
+// when there are none, the variables that are never defined. Running txt
+// only fails on those when they are read.
+func (this *Interpreter) Check(txt string) Errors {
+	if this.Globals == nil {
+		this.Init()
+	}
+	this.parser.Locale = this.Locale
+	nodes, err := this.parser.Parse(txt)
+	if errs, ok := err.(Errors); ok && len(errs) > 0 {
+		return errs
+	}
+	locals := Create_Object(make(map[string]Value)).(Object)
+	locals.Create_Var("__name__", 0, Create_String("__main__"), true)
+	_, errs := resolve(nodes, func(name string) bool {
+		return this.find_scope(&locals, name) != nil
+	}, func() []string {
+		return this.visible_names(&locals)
+	}, nil)
+	re := Errors{}
+	for _, e := range errs {
+		if v, ok := e.(Error); ok {
+			re = append(re, v)
+		}
+	}
+	return localize(re, this.Locale).(Errors)
+}
+
+// run executes the top level nodes of a script with the engine selected by
+// Use_VM and returns the value of the last one.
+func (this *Interpreter) run(nodes []Value, locals *Object) (Value, any) {
+	if this.Optimize {
+		nodes = Optimize(nodes)
+	}
+	// a name neither the script, locals nor Globals define is reported
+	// before anything runs
+	env := module_frame(locals)
+	slots, errs := resolve(nodes, func(name string) bool {
+		return this.find_scope(locals, name) != nil
+	}, func() []string {
+		return this.visible_names(locals)
+	}, env.module)
+	env.grow(slots)
+	if len(errs) > 0 {
+		re := Errors{}
+		for _, e := range errs {
+			re = append(re, e.(Error))
+		}
+		return nil, re
+	}
+	if this.Use_VM {
+		code := compile_resolved(nodes)
+		if this.Debug {
+			println(code.Disassemble())
+		}
+		re, err := this.run_bytecode(code, env, locals)
+		if err != nil {
+			return nil, this.with_traceback(err)
+		}
+		return re, nil
+	}
+	re := Create_Null()
+	for _, node := range nodes {
+		v, err := this.exec_node(node, locals, env)
+		if r, ok := err.(return_signal); ok {
+			return r.value, nil
+		}
+		if err != nil {
+			return nil, this.with_traceback(err)
+		}
+		if v != nil {
+			re = v
+		}
+	}
+	return re, nil
+}
 func PrintValue(args []Value, line bool) {
 	str := ""
 	for i, s := range args {
@@ -1674,11 +2830,14 @@ func PrintValue(args []Value, line bool) {
 	}
 }
 func set_obj_global(obj Object, glob *Object) Object {
-	for i := range obj.value {
-		if obj.value[i].Value.VType() == "Object" {
+	for _, i := range obj.Keys() {
+		v, _ := obj.value.Get(i)
+		if v.Value.VType() == "Object" {
 			obj.On_set_attr(i, set_obj_global(obj.On_get_attr(i).(Object), glob))
-		} else if obj.value[i].Value.VType() == "Function" {
-			f := obj.value[i].Value.(Function)
+		} else if f, ok := v.Value.(Function); ok {
+			f.locals = glob
+			obj.On_set_attr(i, f)
+		} else if f, ok := v.Value.(Closure); ok {
 			f.locals = glob
 			obj.On_set_attr(i, f)
 		}
@@ -1710,7 +2869,10 @@ func Create_Context(inter *Interpreter) Value {
 				}
 				nodes, _ := inter.parser.Parse(string(txt))
 				l := Create_Object(make(map[string]Value)).(Object)
-				return Create_Function(nodes, inter, &l, []Variable{}), nil
+				f := Create_Function(nodes, inter, &l, []Variable{}).(Function)
+				f.slots, _ = Resolve(nodes, nil)
+				f.module = src
+				return f, nil
 			}),
 		}),
 		"exec": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
@@ -1733,6 +2895,124 @@ func Create_Context(inter *Interpreter) Value {
 		"globals": inter.Globals,
 	})
 }
+func to_object(value Value) (Object, bool) {
+	switch v := value.(type) {
+	case Object:
+		return v, true
+	case *Object:
+		return *v, true
+	case Pointer:
+		return to_object(v.value.Value)
+	}
+	return Object{}, false
+}
+func get_object(args []Value, kwargs map[string]*Variable, index int, name string) (Object, any) {
+	v, ok := get_arg(args, kwargs, index, name)
+	if !ok {
+		v = Create_Null()
+	}
+	if obj, ok := to_object(v); ok {
+		return obj, nil
+	}
+	return Object{}, Error{code: Code_Not_Object, kind: "erro3", key: "erro msg11", args: []string{v.Re_string("")}}
+}
+func Create_Object_Helpers() Value {
+	return Create_Object(map[string]Value{
+		"keys": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			obj, err := get_object(args, kwargs, 0, "object")
+			if err != nil {
+				return Create_Null(), err
+			}
+			re := []Value{}
+			for _, k := range obj.Keys() {
+				re = append(re, Create_String(k))
+			}
+			return Create_Array(re), nil
+		}),
+		"values": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			obj, err := get_object(args, kwargs, 0, "object")
+			if err != nil {
+				return Create_Null(), err
+			}
+			re := []Value{}
+			for _, k := range obj.Keys() {
+				re = append(re, obj.On_get_attr(k))
+			}
+			return Create_Array(re), nil
+		}),
+		"entries": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			obj, err := get_object(args, kwargs, 0, "object")
+			if err != nil {
+				return Create_Null(), err
+			}
+			re := []Value{}
+			for _, k := range obj.Keys() {
+				re = append(re, Create_Array([]Value{Create_String(k), obj.On_get_attr(k)}))
+			}
+			return Create_Array(re), nil
+		}),
+		"has": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			obj, err := get_object(args, kwargs, 0, "object")
+			if err != nil {
+				return Create_Null(), err
+			}
+			key, _ := get_arg(args, kwargs, 1, "key")
+			if key == nil {
+				return Create_Bool(false), nil
+			}
+			return Create_Bool(obj.value.Has(key.Re_string(""))), nil
+		}),
+		"delete": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			obj, err := get_object(args, kwargs, 0, "object")
+			if err != nil {
+				return Create_Null(), err
+			}
+			key, _ := get_arg(args, kwargs, 1, "key")
+			if key == nil || obj.Is_frozen() {
+				return Create_Bool(false), nil
+			}
+			return Create_Bool(obj.Delete_Var(key.Re_string(""))), nil
+		}),
+		"assign": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			obj, err := get_object(args, kwargs, 0, "target")
+			if err != nil {
+				return Create_Null(), err
+			}
+			for i := 1; i < len(args); i++ {
+				source, err := get_object(args, kwargs, i, "")
+				if err != nil {
+					return Create_Null(), err
+				}
+				for _, k := range source.Keys() {
+					obj.On_set_attr(k, source.On_get_attr(k))
+				}
+			}
+			return obj, nil
+		}),
+		"freeze": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			obj, err := get_object(args, kwargs, 0, "object")
+			if err != nil {
+				return Create_Null(), err
+			}
+			obj.Freeze()
+			return obj, nil
+		}),
+		"from_entries": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			entries, _ := get_arg(args, kwargs, 0, "entries")
+			obj := Create_Object(nil).(Object)
+			if entries == nil || entries.VType() != "Array" {
+				return obj, nil
+			}
+			for _, e := range entries.(Array).Items() {
+				if e.VType() != "Array" || len(e.(Array).Items()) < 2 {
+					continue
+				}
+				obj.On_set_attr(e.(Array).Items()[0].Re_string(""), e.(Array).Items()[1])
+			}
+			return obj, nil
+		}),
+	})
+}
 func (this *Interpreter) Init() {
 	var g Object = Create_Object(map[string]Value{}).(Object)
 	this.Globals = &g
@@ -1844,6 +3124,42 @@ func (this *Interpreter) Init() {
 			return Create_Number(math.Abs(v)), nil
 		}),
 	}), true)
+	this.Set_Global("range", Create_GoFunction(New_Range), true)
+	this.Set_Global("Object", Create_Object_Helpers(), true)
+	this.Set_Global("Map", Create_GoFunction(New_Map), true)
+	this.Set_Global("Set", Create_GoFunction(New_Set), true)
+	this.Set_Global("Tuple", Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+		return Create_Tuple(append([]Value{}, args...)), nil
+	}), true)
+	this.Set_Global("iter", Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+		value, ok := get_arg(args, kwargs, 0, "value")
+		if !ok {
+			value = Create_Null()
+		}
+		it, err := Iter(value)
+		if err != nil {
+			return Create_Null(), err
+		}
+		return it, nil
+	}), true)
+	this.Set_Global("assert", Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+		if value, ok := get_arg(args, kwargs, 0, "value"); ok && value.Re_bool() {
+			return Create_Null(), nil
+		}
+		if message, ok := get_arg(args, kwargs, 1, "message"); ok {
+			return Create_Null(), Error{code: Code_Assertion_Failed, kind: "erro4", key: "erro msg22", args: []string{message.Re_string("")}}
+		}
+		return Create_Null(), Error{code: Code_Assertion_Failed, kind: "erro4", key: "erro msg21"}
+	}), true)
+	if !this.No_Process {
+		process := this.Process
+		if process == nil {
+			process = os_process{args: this.Args}
+		}
+		// not a constant, so process.env.NAME = value reaches the Env;
+		// the object itself is frozen
+		this.Set_Global("process", create_process(process), false)
+	}
 	this.Set_Global("ctx", Create_Context(this), true)
 	this.Set_Global("true", Create_Bool(true), true)
 	this.Set_Global("false", Create_Bool(false), true)
@@ -1855,24 +3171,109 @@ func (this *Interpreter) Init() {
 func (this *Interpreter) Set_Global(name string, value Value, is_const bool) {
 	this.Globals.Create_Var(name, 0, value, is_const)
 }
-func (this *Interpreter) exec_node(nodeV Value, locals *Object, pos int) (Value, any) {
+func (this *Interpreter) exec_node(nodeV Value, locals *Object, env *frame) (Value, any) {
 	node := nodeV.(Node)
 	switch node.Tp {
 	case "value":
 		return node.Value[0], nil
+	case "array":
+		values := []Value{}
+		for _, v := range node.Value {
+			if v.(Node).Tp == "spread" {
+				items, err := this.exec_spread(v.(Node), locals, env)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, items...)
+				continue
+			}
+			item, err := (*Interpreter).exec_node(this, v, locals, env)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, item)
+		}
+		return Create_Array(values), nil
+	case "spread":
+		return nil, Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: node.Line, col: node.Col}
+	case "range":
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
+		if err1 != nil {
+			return nil, err1
+		}
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
+		if err2 != nil {
+			return nil, err2
+		}
+		return Range_Between(v1.Re_number(), v2.Re_number(), 1, node.Value[2].Re_bool())
+	case "return":
+		v, err := (*Interpreter).exec_node(this, node.Value[0], locals, env)
+		if err != nil {
+			return nil, err
+		}
+		return nil, return_signal{value: v}
+	case "yield":
+		if env == nil || env.gen == nil {
+			return nil, Error{code: Code_Yield_Outside_Generator, kind: "erro1", key: "erro msg14", line: node.Line, col: node.Col}
+		}
+		v, err := (*Interpreter).exec_node(this, node.Value[0], locals, env)
+		if err != nil {
+			return nil, err
+		}
+		return env.gen.yield_value(v)
+	case "for":
+		iterable, err := (*Interpreter).exec_node(this, node.Value[1], locals, env)
+		if err != nil {
+			return nil, err
+		}
+		it, err := Iter(iterable)
+		if err != nil {
+			return nil, Error{line: node.Line, col: node.Col, other_error: err}
+		}
+		slots, own := loop_slots(node)
+		for {
+			v, ok, err := it.On_next()
+			if err != nil {
+				return nil, Error{line: node.Line, col: node.Col, other_error: err}
+			}
+			if !ok {
+				break
+			}
+			body := env
+			if own {
+				body = env.iteration(slots)
+			}
+			err = this.destructure(node.Value[0].(Node), v, locals, body, false)
+			for i := 0; err == nil && i < len(node.Value[2].(Node).Value); i++ {
+				_, err = this.exec_node(node.Value[2].(Node).Value[i], locals, body)
+			}
+			if err != nil {
+				it.On_close()
+				return nil, err
+			}
+		}
 	case "()":
-		obj, err := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		obj, err := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err != nil {
 			return nil, err
 		}
 		return obj, nil
 	case "get attr":
-		obj, err := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		obj, err := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err != nil {
 			return nil, err
 		}
-		return Get_attr(obj, node.Value[1]), nil
-	case "function":
+		path := attr_path(node.Value[1])
+		if len(path) == 0 {
+			return Get_attr(obj, node.Value[1]), nil
+		}
+		for _, name := range path {
+			if obj, err = read_attr(obj, name, node.Line, node.Col); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case "function", "generator":
 		args := []Variable{}
 		for _, v := range node.Value[1].(Node).Value {
 			nodeVa := v.(Node)
@@ -1880,46 +3281,57 @@ func (this *Interpreter) exec_node(nodeV Value, locals *Object, pos int) (Value,
 				args = append(args, Variable{name: nodeVa.Value[0].Re_string(""), Value: Create_Null()})
 			}
 		}
-		function := Create_Function(node.Value[2].(Node).Value, this, locals, args)
-		if node.Value[0].Re_string("") != "" {
-			locals.Create_Var(node.Value[0].Re_string(""), pos, function, false)
-		}
-		return function, nil
+		f := Create_Function(node.Value[2].(Node).Value, this, locals, args).(Function)
+		f.generator = node.Tp == "generator"
+		f.name = node.Value[0].Re_string("")
+		f.module = this.module
+		f.env = env
+		slot := -1
+		f.slots, slot = function_slots(node)
+		if slot >= 0 {
+			env.set(slot, f)
+		} else if node.Value[0].Re_string("") != "" {
+			locals.Create_Var(node.Value[0].Re_string(""), 0, f, false)
+		}
+		return f, nil
 	case "exist":
-		ok := In(locals, node.Value[0])
-		if ok.Re_bool() {
-			return ok, nil
-		}
-		ok = this.Globals.On_in(node.Value[0])
-		if ok.Re_bool() {
-			return ok, nil
+		if _, _, ok := var_slot(node.Value[0].(Node)); ok {
+			return Create_Bool(true), nil
 		}
-		return Create_Bool(false), nil
+		return Create_Bool(this.find_scope(locals, node.Value[0].(Node).Value[0].Re_string("")) != nil), nil
 	case "call":
 		args := []Value{}
 		kwargs := make(map[string]*Variable)
 		for _, v := range node.Value[1].(Node).Value {
 			nodeVa := v.(Node)
-			if nodeVa.Tp == "=" {
-				v, err := (*Interpreter).exec_node(this, nodeVa.Value[1], locals, pos)
+			if nodeVa.Tp == "spread" {
+				items, err := this.exec_spread(nodeVa, locals, env)
+				if err != nil {
+					return Create_Null(), err
+				}
+				args = append(args, items...)
+			} else if nodeVa.Tp == "=" {
+				v, err := (*Interpreter).exec_node(this, nodeVa.Value[1], locals, env)
 				if err != nil {
 					return Create_Null(), err
 				}
 				kwargs[nodeVa.Value[0].(Node).Value[0].Re_string("")] = &Variable{Value: v, name: nodeVa.Value[0].(Node).Value[0].Re_string("")}
 			} else {
-				v, err := (*Interpreter).exec_node(this, nodeVa, locals, pos)
+				v, err := (*Interpreter).exec_node(this, nodeVa, locals, env)
 				if err != nil {
 					return Create_Null(), err
 				}
 				args = append(args, v)
 			}
 		}
-		obj, err := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		obj, err := this.exec_callee(node.Value[0].(Node), locals, env)
 		if err != nil {
 			return Create_Null(), err
 		}
 		var re Value
-		re, err = Call(obj, args, kwargs, pos)
+		this.push_call(obj, node.Value[0], node.Value[0].(Node).Line, node.Value[0].(Node).Col)
+		re, err = Call(obj, args, kwargs, 0)
+		err = this.end_call(err)
 		var re_err any
 		if err != nil {
 			re_err = Error{line: node.Value[0].(Node).Line, col: node.Value[0].(Node).Col, other_error: err}
@@ -1929,19 +3341,15 @@ func (this *Interpreter) exec_node(nodeV Value, locals *Object, pos int) (Value,
 		if node.Value[0].(Node).Tp == "get attr" {
 
 		} else {
-			v, ok := locals.On_get_Variable(node.Value[0].(Node).Value[0].Re_string(""))
-			if ok {
-				return Create_Pointer(v), nil
-			}
-			v, ok = this.Globals.On_get_Variable(node.Value[0].(Node).Value[0].Re_string(""))
-			if ok {
+			if scope := this.find_scope(locals, node.Value[0].(Node).Value[0].Re_string("")); scope != nil {
+				v, _ := scope.On_get_Variable(node.Value[0].(Node).Value[0].Re_string(""))
 				return Create_Pointer(v), nil
 			}
 		}
 		break
 	case "*f":
 		if node.Value[0].(Node).Tp == "=" {
-			obj, err := (*Interpreter).exec_node(this, node.Value[0].(Node).Value[1], locals, pos)
+			obj, err := (*Interpreter).exec_node(this, node.Value[0].(Node).Value[1], locals, env)
 			if node.Value[0].(Node).Tp == "get attr" {
 				v, ok := locals.On_get_Variable(node.Value[0].(Node).Value[0].(Node).Value[0].Re_string(""))
 				ok = ok && !v.is_const
@@ -1987,32 +3395,34 @@ func (this *Interpreter) exec_node(nodeV Value, locals *Object, pos int) (Value,
 		if node.Value[0].(Node).Tp == "var" {
 			this.Globals.Create_Var(node.Value[0].(Node).Value[0].Re_string(""), 0, Create_Null(), false)
 		} else if node.Value[0].(Node).Tp == "=" {
-			obj, err := (*Interpreter).exec_node(this, node.Value[0].(Node).Value[1], locals, pos)
-			this.Globals.Create_Var(node.Value[0].(Node).Value[0].(Node).Value[0].Re_string(""), 0, obj, false)
-			return obj, err
+			obj, err := (*Interpreter).exec_node(this, node.Value[0].(Node).Value[1], locals, env)
+			if err != nil {
+				return nil, err
+			}
+			return obj, this.destructure(node.Value[0].(Node).Value[0].(Node), obj, locals, env, true)
 		}
 		break
 	case "create local":
 		if node.Value[0].(Node).Tp == "var" {
-			locals.Create_Var(node.Value[0].(Node).Value[0].Re_string(""), pos, Create_Null(), false)
+			this.destructure(node.Value[0].(Node), Create_Null(), locals, env, false)
 		} else if node.Value[0].(Node).Tp == "=" {
-			obj, err := (*Interpreter).exec_node(this, node.Value[0].(Node).Value[1], locals, pos)
-			locals.Create_Var(node.Value[0].(Node).Value[0].(Node).Value[0].Re_string(""), pos, obj, false)
-			return obj, err
+			obj, err := (*Interpreter).exec_node(this, node.Value[0].(Node).Value[1], locals, env)
+			if err != nil {
+				return nil, err
+			}
+			return obj, this.destructure(node.Value[0].(Node).Value[0].(Node), obj, locals, env, false)
 		}
 		break
 	case "var":
-		ok := In(locals, node).(Bool).Value
-		if ok {
-			return locals.On_get_attr(node.Value[0].Re_string("")), nil
+		if depth, slot, ok := var_slot(node); ok {
+			return this.load_slot(env, depth, slot, locals, node.Line, node.Col)
 		}
-		ok = In(this.Globals, node).(Bool).Value
-		if ok {
-			return this.Globals.On_get_attr(node.Value[0].Re_string("")), nil
+		if scope := this.find_scope(locals, node.Value[0].Re_string("")); scope != nil {
+			return scope.On_get_attr(node.Value[0].Re_string("")), nil
 		}
-		return Create_Null(), Error{msg: lang_text("erro2", []string{}) + lang_text("err msg5", []string{node.Value[0].Re_string("")}), line: node.Line, col: node.Col}
+		return Create_Null(), var_error(node.Value[0].Re_string(""), node.Line, node.Col, append(this.visible_names(locals), var_hints(node)...))
 	case "=":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		/*if node.Value[0].(Node).Tp == "get attr" {
 			v, ok := locals.On_get_Variable(node.Value[0].(Node).Value[0].(Node).Value[0].Re_string())
 			ok = ok && !v.is_const
@@ -2034,148 +3444,279 @@ func (this *Interpreter) exec_node(nodeV Value, locals *Object, pos int) (Value,
 			}
 			return v.Value, err1
 		} else {*/
-		ok := In(locals, node.Value[0]).(Bool).Value
+		target := node.Value[0].(Node)
+		if target.Tp == "get attr" {
+			target = target.Value[0].(Node)
+		}
 		if err1 != nil {
 			return nil, err1
 		}
-		if ok {
-			if (node.Value[0].(Node).Tp == "get attr" && locals.value[node.Value[0].(Node).Value[0].(Node).Value[0].Re_string("")].is_const) || (node.Value[0].(Node).Tp == "var" && locals.value[node.Value[0].(Node).Value[0].Re_string("")].is_const) {
-				return v1, nil
+		if depth, slot, ok := var_slot(target); ok {
+			if node.Value[0].(Node).Tp != "get attr" {
+				return v1, this.store_slot(env, depth, slot, v1, locals, node.Line, node.Col)
+			}
+			obj, err := this.load_slot(env, depth, slot, locals, node.Line, node.Col)
+			if err != nil {
+				return nil, err
 			}
-			*locals = Set_attr(locals, node.Value[0], v1).(Object)
+			Set_attr(obj, node.Value[0].(Node).Value[1], v1)
 			return v1, nil
 		}
-		ok = In(this.Globals, node.Value[0]).(Bool).Value
-		if ok {
-			if (node.Value[0].(Node).Tp == "get attr" && this.Globals.value[node.Value[0].(Node).Value[0].(Node).Value[0].Re_string("")].is_const) || (node.Value[0].(Node).Tp == "var" && this.Globals.value[node.Value[0].(Node).Value[0].Re_string("")].is_const) {
+		if scope := this.find_scope(locals, target.Value[0].Re_string("")); scope != nil {
+			if scope.is_const(target.Value[0].Re_string("")) {
 				return v1, nil
 			}
-			*this.Globals = Set_attr(this.Globals, node.Value[0], v1).(Object)
+			Set_attr(*scope, node.Value[0], v1)
 			return v1, nil
 		}
-		return Create_Null(), Error{msg: lang_text("erro2", []string{}) + lang_text("err msg5", []string{node.Value[0].(Node).Value[0].Re_string("")}), line: node.Line, col: node.Col}
+		return Create_Null(), var_error(target.Value[0].Re_string(""), node.Line, node.Col, append(this.visible_names(locals), var_hints(target)...))
 		//}
 	case "inverse number":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
 		return Create_Number(-v1.Re_number()), nil
 	case "+":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
-		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		if err2 != nil {
 			return nil, err2
 		}
 		return Sum(v1, v2), nil
 	case "-":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
-		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		if err2 != nil {
 			return nil, err2
 		}
 		return Sub(v1, v2), nil
 	case "*":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
-		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		if err2 != nil {
 			return nil, err2
 		}
 		return Mul(v1, v2), nil
 	case "/":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
-		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		if err2 != nil {
 			return nil, err2
 		}
 		return Div(v1, v2), nil
 	case "==":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
+		if err1 != nil {
+			return nil, err1
+		}
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
+		if err2 != nil {
+			return nil, err2
+		}
+		return Create_Bool(Equal(v1, v2)), nil
+	case "in":
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
-		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		if err2 != nil {
 			return nil, err2
 		}
-		return Create_Bool(v1 == v2), nil
+		return In(v2, v1), nil
 	case "&&":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
-		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		if err2 != nil {
 			return nil, err2
 		}
 		return Create_Bool(v1.Re_bool() && v2.Re_bool()), nil
 	case "||":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
-		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, pos)
+		v2, err2 := (*Interpreter).exec_node(this, node.Value[1], locals, env)
 		if err2 != nil {
 			return nil, err2
 		}
 		return Create_Bool(v1.Re_bool() || v2.Re_bool()), nil
 	case "if":
-		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, pos)
+		v1, err1 := (*Interpreter).exec_node(this, node.Value[0], locals, env)
 		if err1 != nil {
 			return nil, err1
 		}
 		if v1.Re_bool() {
 			for _, nv := range node.Value[1].(Node).Value {
-				_, err := this.exec_node(nv, locals, pos+1)
+				_, err := this.exec_node(nv, locals, env)
 				if err != nil {
 					return nil, err
 				}
 			}
-			for v := range locals.value {
-				if locals.value[v].Pos >= pos+1 {
-					delete(locals.value, v)
+		}
+	}
+
+	return Create_Null(), nil
+}
+
+// return_signal unwinds the blocks between a "return" node and the call
+// of the function it belongs to.
+type return_signal struct {
+	value Value
+}
+
+// find_scope returns the Object that holds name, walking from locals up
+// through its parents and ending at Globals, or nil when it is undefined.
+func (this *Interpreter) find_scope(locals *Object, name string) *Object {
+	for scope := locals; scope != nil; scope = scope.meta.parent {
+		if scope.value.Has(name) {
+			return scope
+		}
+	}
+	if this.Globals.value.Has(name) {
+		return this.Globals
+	}
+	return nil
+}
+
+// exec_callee evaluates the function of a "call" node. Calling an
+// attribute the value doesn't have is an error, not a call of null.
+func (this *Interpreter) exec_callee(node Node, locals *Object, env *frame) (Value, any) {
+	if node.Tp != "get attr" || len(attr_path(node.Value[1])) == 0 {
+		return this.exec_node(node, locals, env)
+	}
+	path := attr_path(node.Value[1])
+	obj, err := this.exec_node(node.Value[0], locals, env)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range path {
+		if obj, err = read_attr(obj, name, node.Line, node.Col); err != nil {
+			return nil, err
+		}
+	}
+	return obj, nil
+}
+func (this *Interpreter) exec_spread(node Node, locals *Object, env *frame) ([]Value, any) {
+	v, err := (*Interpreter).exec_node(this, node.Value[0], locals, env)
+	if err != nil {
+		return nil, err
+	}
+	values, err := Collect(v)
+	if err != nil {
+		return nil, Error{line: node.Line, col: node.Col, other_error: err}
+	}
+	return values, nil
+}
+
+// destructure binds value to target, which is either a "var" node or an
+// "array" node of targets whose last item may be a "spread" collecting
+// the rest.
+func (this *Interpreter) destructure(target Node, value Value, locals *Object, env *frame, global bool) any {
+	switch target.Tp {
+	case "var":
+		if global {
+			this.Globals.Create_Var(target.Value[0].Re_string(""), 0, value, false)
+		} else if _, slot, ok := var_slot(target); ok {
+			env.set(slot, value)
+		} else {
+			locals.Create_Var(target.Value[0].Re_string(""), 0, value, false)
+		}
+		return nil
+	case "array":
+		it, err := Iter(value)
+		if err != nil {
+			return Error{line: target.Line, col: target.Col, other_error: err}
+		}
+		defer it.On_close()
+		for _, t := range target.Value {
+			if t.(Node).Tp == "spread" {
+				rest, err := Collect(it)
+				if err != nil {
+					return err
 				}
+				return this.destructure(t.(Node).Value[0].(Node), Create_Array(rest), locals, env, global)
+			}
+			v, ok, err := it.On_next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				v = Create_Null()
+			}
+			if err := this.destructure(t.(Node), v, locals, env, global); err != nil {
+				return err
 			}
 		}
+		return nil
 	}
+	return Error{code: Code_Invalid_Expression, kind: "erro1", key: "erro msg6", line: target.Line, col: target.Col}
+}
 
-	return Create_Null(), nil
+// Exec_Main runs the script at src as the main one. The error is the one
+// of reading src, its syntax errors or the error it raised; an Exit when
+// it called process.exit.
+func (this *Interpreter) Exec_Main(src string) (Value, error) {
+	txt, err := os.ReadFile(src)
+	if err != nil {
+		return Create_Null(), err
+	}
+	return this.Exec_Main_Text(string(txt), src)
 }
-func (this *Interpreter) Exec_Main(src string) Value {
+
+// Exec_Main_Text runs txt as the main script, naming it src in the
+// tracebacks, for scripts that don't come from a file.
+func (this *Interpreter) Exec_Main_Text(txt string, src string) (Value, error) {
 	this.Init()
 	locals := Create_Object(make(map[string]Value)).(Object)
 	locals.Create_Var("__name__", 0, Create_String("__main__"), true)
-	txt, _ := os.ReadFile(src)
-	re := (*Interpreter).Eval(this, string(txt), &locals)
+	this.module = src
+	re, err := (*Interpreter).Eval(this, txt, &locals)
 	if this.Debug {
 		fmt.Println(re.Re_string(""))
 	}
-	return re
+	return re, err
 }
-func (this *Interpreter) Exec_Module(src string) (Object, any) {
+
+// Exec_Module runs the script at src and returns the Object holding the
+// variables it declared.
+func (this *Interpreter) Exec_Module(src string) (Value, error) {
+	txt, err := os.ReadFile(src)
+	if err != nil {
+		return Create_Null(), err
+	}
 	this.Init()
 	locals := Create_Object(make(map[string]Value)).(Object)
 	locals.Create_Var("__name__", 0, Create_String("__main__"), true)
-	txt, _ := os.ReadFile(src)
-	err := (*Interpreter).Exec(this, string(txt), &locals)
+	module := this.module
+	this.module = src
+	err2 := (*Interpreter).Exec(this, string(txt), &locals)
+	this.module = module
 	locals = set_obj_global(locals, &locals)
-	return locals, err
+	return locals, to_error(err2)
 }
 
 func conv_error_in_str(e Error) string {
-	re := e.msg
+	re := e.message()
+	if e.line == 0 || e.line > uint64(len(e.lines)) {
+		return re
+	}
 	re += " line:" + fmt.Sprint(e.line) + ",collum:" + fmt.Sprint(e.col) + "\n"
 	re += e.lines[e.line-1] + "\n"
 
@@ -2188,21 +3729,77 @@ func conv_error_in_str(e Error) string {
 	}
 	return re
 }
+
+// with_lines sets the locale and the source an Error is shown with.
+func (this *Interpreter) with_lines(err any, txt string) any {
+	err = localize(err, this.Locale)
+	switch e := err.(type) {
+	case Error:
+		e.lines = strings.Split(txt, "\n")
+		return e
+	case Errors:
+		for i := range e {
+			e[i].lines = strings.Split(txt, "\n")
+		}
+	}
+	return err
+}
 func livre(e any, txt []string) bool {
-	if e != nil {
-		a := e.(Error)
-		txt = append(txt, conv_error_in_str(a)+"\n")
-		if a.other_error != nil {
-			v := a.other_error.(Error)
-			v.lines = a.lines
-			livre(v, txt)
-			return false
+	if errs, ok := e.(Errors); e == nil || ok && len(errs) == 0 {
+		return true
+	}
+	print(conv_error(e, txt))
+	return false
+}
+
+// conv_error is the text livre shows for e: the error with the line it
+// points at, then its causes, or the traceback of a runtime error.
+func conv_error(e any, txt []string) string {
+	if a, ok := e.(Error); ok && a.Traceback() != nil {
+		return conv_traceback(a, a.Traceback()) + "\n"
+	}
+	if errs, ok := e.(Errors); ok {
+		re := ""
+		for _, v := range errs {
+			re += conv_error(v, nil)
 		}
-		for _, v := range txt {
-			print(v)
+		return re
+	}
+	a, ok := e.(Error)
+	if !ok {
+		return fmt.Sprint(e) + "\n"
+	}
+	txt = append(txt, conv_error_in_str(a)+"\n")
+	if a.other_error != nil {
+		if v, ok := a.other_error.(Error); ok {
+			v.lines = a.lines
+			return conv_error(v, txt)
 		}
-		return false
+		txt = append(txt, fmt.Sprint(a.other_error)+"\n")
 	}
+	return strings.Join(txt, "")
+}
 
-	return true
+// Format_Error renders err, an error returned by the Parser, Resolve or the
+// Interpreter, like the interpreter prints it, in its Locale and showing
+// the lines of src it points at.
+func (this *Interpreter) Format_Error(err any, src string) string {
+	lines := strings.Split(src, "\n")
+	switch e := localize(err, this.Locale).(type) {
+	case Error:
+		if e.lines == nil {
+			e.lines = lines
+		}
+		err = e
+	case Errors:
+		re := make(Errors, len(e))
+		for i, v := range e {
+			if v.lines == nil {
+				v.lines = lines
+			}
+			re[i] = v
+		}
+		err = re
+	}
+	return conv_error(err, nil)
 }
@@ -0,0 +1,84 @@
+package kll
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestZeroArray(t *testing.T) {
+	for _, a := range []Array{{}, {Value: nil}} {
+		if got := a.Re_string(""); got != "[]" {
+			t.Errorf("got %s, want []", got)
+		}
+		if got := a.On_get_attr("length").Re_number(); got != 0 {
+			t.Errorf("length: got %v, want 0", got)
+		}
+		for _, method := range []string{"push", "pop", "shift", "unshift", "splice", "slice", "join"} {
+			if _, err := Call(a.On_get_attr(method), []Value{}, map[string]*Variable{}, 0); err != nil {
+				t.Errorf("%s: %v", method, err)
+			}
+		}
+		items, err := Collect(a)
+		if err != nil || len(items) != 0 {
+			t.Errorf("iterating: got %v, %v", items, err)
+		}
+	}
+	a := Create_Array(nil).(Array)
+	b := a
+	Call(b.On_get_attr("push"), []Value{Create_Number(1)}, map[string]*Variable{}, 0)
+	if got := a.Re_string(""); got != "[1]" {
+		t.Errorf("a copy of an Array does not share its elements: got %s", got)
+	}
+}
+
+// TestParseErrors checks that Parse reports every syntax error of the
+// text, each on its own line, after recovering from the ones before.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		lines  []uint64
+	}{
+		{name: "operator before a declaration", source: `var a = 1 +
+var b = 2`, lines: []uint64{1}},
+		{name: "one per line", source: `var a = )
+var b = 1
+var c = *
+var d = 2 +`, lines: []uint64{1, 3, 4}},
+		{name: "unclosed parameters", source: `function f( {
+}
+var c = 1
+var d = )
+var e = 2`, lines: []uint64{1, 4}},
+		{name: "unclosed condition", source: `if (1 {
+	var a = 1
+}
+var b = +`, lines: []uint64{1, 4}},
+		{name: "call spanning lines", source: `console.log(1,
+	2 +,
+	3)
+var ok = 1
+var bad = *`, lines: []uint64{2, 5}},
+		{name: "inside a function", source: `function g() {
+	var a = )
+	var b = 1 +
+	return a
+}
+var c = ]`, lines: []uint64{2, 3, 6}},
+	}
+	for _, test := range tests {
+		_, err := (&Parser{}).Parse(test.source)
+		errs, _ := err.(Errors)
+		lines := []uint64{}
+		for _, e := range errs {
+			lines = append(lines, e.line)
+			if !strings.HasPrefix(string(e.code), "KLL1") {
+				t.Errorf("%s: error %s at line %d is not a syntax error", test.name, e.code, e.line)
+			}
+		}
+		if fmt.Sprint(lines) != fmt.Sprint(test.lines) {
+			t.Errorf("%s: errors at lines %v, want %v\n%v", test.name, lines, test.lines, err)
+		}
+	}
+}
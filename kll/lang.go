@@ -0,0 +1,153 @@
+package kll
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Messages is the catalog of one language: the text of each message key,
+// with {0}, {1}… replaced by the extras of the message.
+type Messages map[string]string
+
+// Default_Locale is the language used when an Interpreter or a Parser has
+// no Locale, and the one a message missing from a catalog falls back to.
+var Default_Locale = "pt-br"
+
+var catalogs = map[string]Messages{
+	"pt-br": {
+		"erro1":                "Erro de Syntaxe: ",
+		"erro2":                "Erro de Variavel: ",
+		"erro3":                "Erro de Tipo: ",
+		"erro4":                "Erro de Asserção: ",
+		"erro5":                "Aviso: ",
+		"erro msg1":            "no numero possui mais de 1 ponto final",
+		"erro msg2":            "o simbolo {0} não existe nessa linguagem",
+		"erro msg3":            "vc colocol em uma posição errada",
+		"erro msg4":            "vc esqueceu de fechar uma string",
+		"erro msg5":            "A variavel '{0}' não existe",
+		"erro msg6":            "expresão invalida",
+		"erro msg7":            "vc esqueceu de fechar as chaves",
+		"erro msg8":            "vc esqueceu de fechar os parentses",
+		"erro msg9":            "vc esqueceu de fechar os colchetes",
+		"erro msg10":           "o valor '{0}' não é uma função",
+		"erro msg11":           "o valor '{0}' não é um objeto",
+		"erro msg12":           "o valor '{0}' não pode ser usado como chave",
+		"erro msg13":           "o valor '{0}' não é iterável",
+		"erro msg14":           "yield só pode ser usado dentro de um gerador",
+		"erro msg15":           "o passo de um range não pode ser zero",
+		"erro msg16":           "o benchmark '{0}' retornou '{1}' em vez de '{2}'",
+		"erro msg17":           "A variavel '{0}' não existe; vc quis dizer '{1}'?",
+		"erro msg18":           "o tipo '{0}' não tem o atributo '{1}'",
+		"erro msg19":           "o tipo '{0}' não tem o atributo '{1}'; vc quis dizer '{2}'?",
+		"erro msg20":           "o tipo '{0}' não tem o atributo '{1}'; os atributos dele são: {2}",
+		"erro msg21":           "a asserção falhou",
+		"erro msg22":           "{0}",
+		"erro msg23":           "a variavel '{0}' foi criada mas nunca é usada",
+		"erro msg24":           "a variavel '{0}' esconde a que foi criada na linha {1}",
+		"erro msg25":           "'{0}' é constante, essa atribuição não faz nada",
+		"erro msg26":           "esse código nunca roda, ele vem depois de um return",
+		"erro msg27":           "um valor do tipo '{0}' não pode ser chamado",
+		"erro msg28":           "essa comparação é sempre falsa",
+		"erro msg29":           "global cria '{0}' para todos os modulos quando a função roda; crie com var fora da função",
+		"erro msg30":           "o codigo de saída deve ser um numero inteiro de 0 a 255, não '{0}'",
+		"erro msg31":           "a variavel '{0}' cria de novo o parametro da linha {1}",
+		"repl banner":          "kll repl, digite .help para ajuda",
+		"repl help":            ".help        mostra essa ajuda\n.load file   roda o arquivo nessa sessão\n.ast expr    mostra os nós de expr\n.exit        sai (ou ctrl-d)",
+		"repl unknown command": "o comando '{0}' não existe, digite .help",
+		"traceback":            "Traceback (chamada mais recente por último):",
+		"traceback frame":      "  Arquivo \"{0}\", linha {1}, em {2}",
+		"traceback go frame":   "  Arquivo \"{0}\", em {1}",
+	},
+	"en": {
+		"erro1":                "Syntax Error: ",
+		"erro2":                "Variable Error: ",
+		"erro3":                "Type Error: ",
+		"erro4":                "Assertion Error: ",
+		"erro5":                "Warning: ",
+		"erro msg1":            "the number has more than 1 decimal point",
+		"erro msg2":            "the symbol {0} does not exist in this language",
+		"erro msg3":            "this is in the wrong place",
+		"erro msg4":            "you forgot to close a string",
+		"erro msg5":            "The variable '{0}' does not exist",
+		"erro msg6":            "invalid expression",
+		"erro msg7":            "you forgot to close the braces",
+		"erro msg8":            "you forgot to close the parentheses",
+		"erro msg9":            "you forgot to close the brackets",
+		"erro msg10":           "the value '{0}' is not a function",
+		"erro msg11":           "the value '{0}' is not an object",
+		"erro msg12":           "the value '{0}' can not be used as a key",
+		"erro msg13":           "the value '{0}' is not iterable",
+		"erro msg14":           "yield can only be used inside a generator",
+		"erro msg15":           "the step of a range can not be zero",
+		"erro msg16":           "the benchmark '{0}' returned '{1}' instead of '{2}'",
+		"erro msg17":           "The variable '{0}' does not exist; did you mean '{1}'?",
+		"erro msg18":           "the type '{0}' has no attribute '{1}'",
+		"erro msg19":           "the type '{0}' has no attribute '{1}'; did you mean '{2}'?",
+		"erro msg20":           "the type '{0}' has no attribute '{1}'; its attributes are: {2}",
+		"erro msg21":           "the assertion failed",
+		"erro msg22":           "{0}",
+		"erro msg23":           "the variable '{0}' is declared but never used",
+		"erro msg24":           "the variable '{0}' shadows the one declared on line {1}",
+		"erro msg25":           "'{0}' is a constant, this assignment does nothing",
+		"erro msg26":           "this code never runs, it comes after a return",
+		"erro msg27":           "a value of type '{0}' can not be called",
+		"erro msg28":           "this comparison is always false",
+		"erro msg29":           "global creates '{0}' for every module when the function runs; declare it with var outside of the function",
+		"erro msg30":           "the exit code must be a whole number from 0 to 255, not '{0}'",
+		"erro msg31":           "the variable '{0}' redeclares the parameter on line {1}",
+		"repl banner":          "kll repl, type .help for help",
+		"repl help":            ".help        shows this help\n.load file   runs the file in this session\n.ast expr    shows the nodes of expr\n.exit        exits (or ctrl-d)",
+		"repl unknown command": "the command '{0}' does not exist, type .help",
+		"traceback":            "Traceback (most recent call last):",
+		"traceback frame":      "  File \"{0}\", line {1}, in {2}",
+		"traceback go frame":   "  File \"{0}\", in {1}",
+	},
+}
+var catalogs_lock sync.RWMutex
+
+// Register_Messages adds the messages to the catalog of locale, creating
+// it if needed, so embedders can translate kll or change its texts.
+func Register_Messages(locale string, messages Messages) {
+	catalogs_lock.Lock()
+	defer catalogs_lock.Unlock()
+	if catalogs[locale] == nil {
+		catalogs[locale] = Messages{}
+	}
+	for k, v := range messages {
+		catalogs[locale][k] = v
+	}
+}
+
+// Locales lists the languages with a catalog.
+func Locales() []string {
+	catalogs_lock.RLock()
+	defer catalogs_lock.RUnlock()
+	re := []string{}
+	for k := range catalogs {
+		re = append(re, k)
+	}
+	return re
+}
+
+func lang_text(txt string, extras []string) string {
+	return lang_text_in("", txt, extras)
+}
+
+// lang_text_in looks txt up in the catalog of locale, then of
+// Default_Locale, and returns txt itself when neither has it.
+func lang_text_in(locale string, txt string, extras []string) string {
+	catalogs_lock.RLock()
+	re, ok := catalogs[locale][txt]
+	if !ok {
+		re, ok = catalogs[Default_Locale][txt]
+	}
+	catalogs_lock.RUnlock()
+	if !ok {
+		re = txt
+	}
+	for i, v := range extras {
+		re = strings.ReplaceAll(re, "{"+strconv.Itoa(i)+"}", v)
+	}
+	return re
+}
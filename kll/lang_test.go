@@ -0,0 +1,53 @@
+package kll
+
+import "testing"
+
+func TestRegisterMessages(t *testing.T) {
+	t.Cleanup(func() {
+		catalogs_lock.Lock()
+		delete(catalogs, "test-full")
+		delete(catalogs, "test-partial")
+		catalogs_lock.Unlock()
+	})
+	Register_Messages("test-full", Messages{"erro2": "Variable trouble: ", "erro msg5": "no '{0}' here"})
+	Register_Messages("test-partial", Messages{"erro msg5": "no '{0}' here"})
+	// registering again adds to the catalog
+	Register_Messages("test-partial", Messages{"erro msg6": "bad expression"})
+	found := 0
+	for _, locale := range Locales() {
+		if locale == "test-full" || locale == "test-partial" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("Locales() = %v, want the registered locales in it", Locales())
+	}
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "test-full", want: "Variable trouble: no 'nada' here"},
+		// erro2 is missing from the catalog, so it comes from Default_Locale
+		{locale: "test-partial", want: "Erro de Variavel: no 'nada' here"},
+		{locale: "unknown", want: "Erro de Variavel: A variavel 'nada' não existe"},
+	}
+	for _, test := range tests {
+		inter := Interpreter{Locale: test.locale}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		_, err := inter.Eval("nada", &locals)
+		e, ok := err.(Errors)
+		if !ok || len(e) != 1 {
+			t.Fatalf("%s: got %v, want one error", test.locale, err)
+		}
+		if got := e[0].Message(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.locale, got, test.want)
+		}
+	}
+	if got := lang_text_in("test-partial", "erro msg6", nil); got != "bad expression" {
+		t.Errorf("the second Register_Messages was lost: got %q", got)
+	}
+	if got := lang_text_in("test-full", "no such key", nil); got != "no such key" {
+		t.Errorf("a key no catalog has: got %q, want the key", got)
+	}
+}
@@ -0,0 +1,219 @@
+package kll
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+var err_interrupt = errors.New("interrupt")
+
+// line_editor reads the lines of the repl. On a terminal it handles the
+// arrow keys, the history and completion itself; otherwise it reads plain
+// lines.
+type line_editor struct {
+	in      *os.File
+	reader  *bufio.Reader
+	out     io.Writer
+	history []string
+	// file is where the history is kept between sessions, "" for none.
+	file string
+	// complete lists the words that can replace the one being typed.
+	complete func(word string) []string
+}
+
+const history_size = 1000
+
+func create_line_editor(in *os.File, out io.Writer, file string) *line_editor {
+	re := &line_editor{in: in, reader: bufio.NewReader(in), out: out, file: file}
+	if file == "" {
+		return re
+	}
+	txt, err := os.ReadFile(file)
+	if err != nil {
+		return re
+	}
+	for _, line := range strings.Split(string(txt), "\n") {
+		if line != "" {
+			re.history = append(re.history, line)
+		}
+	}
+	if len(re.history) > history_size {
+		re.history = re.history[len(re.history)-history_size:]
+	}
+	return re
+}
+
+// add puts line in the history and appends it to the history file.
+func (this *line_editor) add(line string) {
+	if strings.TrimSpace(line) == "" || len(this.history) > 0 && this.history[len(this.history)-1] == line {
+		return
+	}
+	this.history = append(this.history, line)
+	if this.file == "" {
+		return
+	}
+	f, err := os.OpenFile(this.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// read shows prompt and returns the line typed, io.EOF when the input
+// ended and err_interrupt when ctrl-c was pressed.
+func (this *line_editor) read(prompt string) (string, error) {
+	restore, ok := raw_mode(this.in.Fd())
+	if !ok {
+		fmt.Fprint(this.out, prompt)
+		line, err := this.reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	defer restore()
+	line := []rune{}
+	pos := 0
+	hist := len(this.history)
+	draft := ""
+	redraw := func() {
+		fmt.Fprint(this.out, "\r\x1b[K"+prompt+string(line))
+		if n := len(line) - pos; n > 0 {
+			fmt.Fprintf(this.out, "\x1b[%dD", n)
+		}
+	}
+	show := func(txt string) {
+		line = []rune(txt)
+		pos = len(line)
+	}
+	redraw()
+	for {
+		r, _, err := this.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(this.out, "\r\n")
+			return string(line), nil
+		case 3:
+			fmt.Fprint(this.out, "^C\r\n")
+			return "", err_interrupt
+		case 4:
+			if len(line) == 0 {
+				fmt.Fprint(this.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8:
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1:
+			pos = 0
+		case 5:
+			pos = len(line)
+		case 21:
+			line = line[pos:]
+			pos = 0
+		case '\t':
+			line, pos = this.completion(line, pos)
+		case 27:
+			seq := this.escape()
+			switch seq {
+			case "[A":
+				if hist > 0 {
+					if hist == len(this.history) {
+						draft = string(line)
+					}
+					hist--
+					show(this.history[hist])
+				}
+			case "[B":
+				if hist < len(this.history) {
+					hist++
+					if hist == len(this.history) {
+						show(draft)
+					} else {
+						show(this.history[hist])
+					}
+				}
+			case "[C":
+				if pos < len(line) {
+					pos++
+				}
+			case "[D":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+			case "[F", "OF", "[4~":
+				pos = len(line)
+			case "[3~":
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		redraw()
+	}
+}
+
+// escape reads the rest of an escape sequence, like "[A" for the up key.
+func (this *line_editor) escape() string {
+	re := ""
+	for {
+		r, _, err := this.reader.ReadRune()
+		if err != nil {
+			return re
+		}
+		re += string(r)
+		if len(re) > 1 && (r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r == '~') {
+			return re
+		}
+	}
+}
+
+// completion replaces the word before pos with the longest prefix shared by
+// its completions, listing them when that adds nothing.
+func (this *line_editor) completion(line []rune, pos int) ([]rune, int) {
+	if this.complete == nil {
+		return line, pos
+	}
+	start := pos
+	for start > 0 && (line[start-1] == '.' || line[start-1] == '_' || unicode.IsLetter(line[start-1]) || unicode.IsDigit(line[start-1])) {
+		start--
+	}
+	word := string(line[start:pos])
+	options := this.complete(word)
+	if len(options) == 0 {
+		return line, pos
+	}
+	common := options[0]
+	for _, o := range options[1:] {
+		for !strings.HasPrefix(o, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if common == word && len(options) > 1 {
+		fmt.Fprint(this.out, "\r\n"+strings.Join(options, "  ")+"\r\n")
+		return line, pos
+	}
+	re := append(append(append([]rune{}, line[:start]...), []rune(common)...), line[pos:]...)
+	return re, start + len([]rune(common))
+}
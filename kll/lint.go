@@ -0,0 +1,404 @@
+package kll
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Lint_Rule is one of the checks of Lint. Name is how Lint_Config and the
+// lint:ignore comments refer to it.
+type Lint_Rule struct {
+	Name string
+	Code Code
+}
+
+var Lint_Rules = []Lint_Rule{
+	{"unused-variable", Code_Unused_Variable},
+	{"shadowed-variable", Code_Shadowed_Variable},
+	{"const-assignment", Code_Const_Assignment},
+	{"unreachable-code", Code_Unreachable_Code},
+	{"not-callable", Code_Not_Callable_Literal},
+	{"always-false", Code_Always_False},
+	{"implicit-global", Code_Implicit_Global},
+}
+
+// Lint_Config turns rules of Lint off by name; the others run.
+type Lint_Config struct {
+	Disabled map[string]bool
+}
+
+// Lint finds what is likely a mistake in txt, without running it:
+// variables never used, hiding others or redeclaring a parameter,
+// assignments to the constants of Globals, code after a return, calls of
+// values that can't be called, comparisons that are always false and
+// globals created inside functions. The findings are Errors with Severity_Warning. Syntax errors
+// are returned instead, as by Check.
+//
+// A comment "// lint:ignore" at the end of a line, or on the line before,
+// hides the warnings of that line; "// lint:ignore-file" hides them in the
+// whole file. Both can be followed by the names of the rules to hide,
+// otherwise they hide all.
+func (this *Interpreter) Lint(txt string, config Lint_Config) Errors {
+	if this.Globals == nil {
+		this.Init()
+	}
+	this.parser.Locale = this.Locale
+	nodes, err := this.parser.Parse(txt)
+	if errs, ok := err.(Errors); ok && len(errs) > 0 {
+		return errs
+	}
+	var l Lexer
+	tokens, _ := l.Tokenizer(txt)
+	r := &linter{
+		scopes:  []*lint_scope{create_lint_scope()},
+		config:  config,
+		ignores: lint_ignores(tokens),
+		is_const: func(name string) bool {
+			return name == "__name__" || this.Globals.is_const(name)
+		},
+	}
+	globals := map[string]bool{}
+	collect_globals(nodes, globals)
+	for name := range globals {
+		r.declare(name, 0, 0, false)
+	}
+	r.block(nodes)
+	sort.SliceStable(r.warnings, func(i, j int) bool {
+		a, b := r.warnings[i], r.warnings[j]
+		return a.line < b.line || a.line == b.line && a.col < b.col
+	})
+	return localize(r.warnings, this.Locale).(Errors)
+}
+
+// lint_ignores reads the lint:ignore comments, returning the rules each
+// hides by line; an empty list hides all of them, and line 0 is the whole
+// file.
+func lint_ignores(tokens []Token) map[uint64][]string {
+	re := map[uint64][]string{}
+	code_line := uint64(0)
+	for _, t := range tokens {
+		switch t.tp {
+		case "new line":
+			continue
+		case "comment":
+		default:
+			code_line = t.line
+			continue
+		}
+		txt := strings.TrimSpace(strings.TrimPrefix(t.value.Re_string(""), "//"))
+		line := t.line
+		if strings.HasPrefix(txt, "lint:ignore-file") {
+			txt, line = strings.TrimPrefix(txt, "lint:ignore-file"), 0
+		} else if strings.HasPrefix(txt, "lint:ignore") {
+			txt = strings.TrimPrefix(txt, "lint:ignore")
+			if code_line != t.line {
+				line++
+			}
+		} else {
+			continue
+		}
+		if txt != "" && txt[0] != ' ' && txt[0] != '\t' {
+			continue
+		}
+		re[line] = append(re[line], strings.FieldsFunc(txt, func(r rune) bool {
+			return r == ' ' || r == ',' || r == '\t'
+		})...)
+	}
+	return re
+}
+
+type linter struct {
+	scopes   []*lint_scope
+	config   Lint_Config
+	ignores  map[uint64][]string
+	is_const func(name string) bool
+	// functions counts the functions the linter is inside of.
+	functions int
+	warnings  Errors
+}
+type lint_scope struct {
+	vars  map[string]*lint_var
+	order []*lint_var
+}
+type lint_var struct {
+	name      string
+	line, col uint64
+	used      bool
+	// declared tells the variables created with var or function, the
+	// ones reported when they are not used, and param the parameters.
+	declared bool
+	param    bool
+}
+
+func create_lint_scope() *lint_scope {
+	return &lint_scope{vars: map[string]*lint_var{}}
+}
+
+func (this *linter) warn(rule string, code Code, key string, args []string, line uint64, col uint64) {
+	if this.config.Disabled[rule] || this.ignored(rule, 0) || this.ignored(rule, line) {
+		return
+	}
+	this.warnings = append(this.warnings, Error{code: code, kind: "erro5", key: key, args: args, line: line, col: col, severity: Severity_Warning})
+}
+func (this *linter) ignored(rule string, line uint64) bool {
+	rules, ok := this.ignores[line]
+	if !ok {
+		return false
+	}
+	if len(rules) == 0 {
+		return true
+	}
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *linter) begin() {
+	this.scopes = append(this.scopes, create_lint_scope())
+}
+
+// end closes the innermost scope, reporting its variables never used.
+// Names starting with _ are meant to be unused.
+func (this *linter) end() {
+	scope := this.scopes[len(this.scopes)-1]
+	this.scopes = this.scopes[:len(this.scopes)-1]
+	for _, v := range scope.order {
+		if v.declared && !v.used && !strings.HasPrefix(v.name, "_") {
+			this.warn("unused-variable", Code_Unused_Variable, "erro msg23", []string{v.name}, v.line, v.col)
+		}
+	}
+}
+
+// declare adds a variable to the innermost scope. It shadows the one of
+// an outer scope only when that one is declared before it in the text, as
+// the variables of the module declared after a function don't exist yet
+// where the function is written.
+func (this *linter) declare(name string, line uint64, col uint64, declared bool) {
+	scope := this.scopes[len(this.scopes)-1]
+	if v, ok := scope.vars[name]; ok {
+		if v.param && declared {
+			// hoisting and the declaration itself both get here
+			v.param = false
+			this.warn("shadowed-variable", Code_Shadowed_Variable, "erro msg31", []string{name, fmt.Sprint(v.line)}, line, col)
+		}
+		return
+	}
+	if len(this.scopes) > 1 {
+		for i := len(this.scopes) - 2; i >= 0; i-- {
+			if v, ok := this.scopes[i].vars[name]; ok {
+				if v.line != 0 && (v.line < line || v.line == line && v.col < col) {
+					this.warn("shadowed-variable", Code_Shadowed_Variable, "erro msg24", []string{name, fmt.Sprint(v.line)}, line, col)
+				}
+				break
+			}
+		}
+	}
+	v := &lint_var{name: name, line: line, col: col, declared: declared && len(this.scopes) > 1}
+	scope.vars[name] = v
+	scope.order = append(scope.order, v)
+}
+func (this *linter) lookup(name string) *lint_var {
+	for i := len(this.scopes) - 1; i >= 0; i-- {
+		if v, ok := this.scopes[i].vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// targets declares the variables of the target of a var or a for, which
+// can be an array destructuring it.
+func (this *linter) targets(target Node, declared bool) {
+	switch target.Tp {
+	case "var":
+		this.declare(target.Value[0].Re_string(""), target.Line, target.Col, declared)
+	case "array":
+		for _, t := range target.Value {
+			if t.(Node).Tp == "spread" {
+				t = t.(Node).Value[0]
+			}
+			this.targets(t.(Node), declared)
+		}
+	}
+}
+
+// hoist declares the variables and named functions of a block first, as
+// the resolver does.
+func (this *linter) hoist(nodes []Value) {
+	for _, v := range nodes {
+		node := v.(Node)
+		switch node.Tp {
+		case "create local":
+			if t := node.Value[0].(Node); t.Tp == "=" {
+				this.targets(t.Value[0].(Node), true)
+			} else {
+				this.targets(t, true)
+			}
+		case "function", "generator":
+			if name := node.Value[0].Re_string(""); name != "" {
+				this.declare(name, node.Line, node.Col, true)
+			}
+		}
+	}
+}
+func (this *linter) block(nodes []Value) {
+	this.hoist(nodes)
+	for i, v := range nodes {
+		this.node(v)
+		if v.(Node).Tp == "return" && i < len(nodes)-1 {
+			line, col := node_start(nodes[i+1].(Node))
+			this.warn("unreachable-code", Code_Unreachable_Code, "erro msg26", nil, line, col)
+			for _, rest := range nodes[i+1:] {
+				this.node(rest)
+			}
+			return
+		}
+	}
+}
+
+// node_start is the position of the first token of node; binary nodes are
+// at their operator.
+func node_start(node Node) (uint64, uint64) {
+	line, col := node.Line, node.Col
+	for _, v := range node.Value {
+		if n, ok := v.(Node); ok {
+			if l, c := node_start(n); l != 0 && (l < line || l == line && c < col) {
+				line, col = l, c
+			}
+		}
+	}
+	return line, col
+}
+func unparen(node Node) Node {
+	for node.Tp == "()" {
+		node = node.Value[0].(Node)
+	}
+	return node
+}
+
+// always_false tells the == whose sides are never Equal: two different
+// literals, or a new array or function, which is equal to nothing else.
+func always_false(a Node, b Node) bool {
+	a, b = unparen(a), unparen(b)
+	for _, n := range []Node{a, b} {
+		switch n.Tp {
+		case "array", "function", "generator":
+			return true
+		}
+	}
+	return a.Tp == "value" && b.Tp == "value" && !Equal(a.Value[0], b.Value[0])
+}
+
+func (this *linter) node(v Value) {
+	node, ok := v.(Node)
+	if !ok {
+		return
+	}
+	switch node.Tp {
+	case "var":
+		if v := this.lookup(node.Value[0].Re_string("")); v != nil {
+			v.used = true
+		}
+	case "exist":
+		this.node(node.Value[0])
+	case "get attr":
+		this.node(node.Value[0])
+	case "call":
+		switch callee := unparen(node.Value[0].(Node)); callee.Tp {
+		case "value":
+			this.warn("not-callable", Code_Not_Callable_Literal, "erro msg27", []string{callee.Value[0].VType()}, callee.Line, callee.Col)
+		case "array":
+			this.warn("not-callable", Code_Not_Callable_Literal, "erro msg27", []string{"Array"}, callee.Line, callee.Col)
+		}
+		this.node(node.Value[0])
+		for _, p := range node.Value[1].(Node).Value {
+			if p.(Node).Tp == "=" {
+				p = p.(Node).Value[1]
+			}
+			this.node(p)
+		}
+	case "=":
+		this.node(node.Value[1])
+		target := node.Value[0].(Node)
+		base := target
+		if target.Tp == "get attr" {
+			base = target.Value[0].(Node)
+		}
+		if base.Tp != "var" {
+			this.node(base)
+			break
+		}
+		name := base.Value[0].Re_string("")
+		v := this.lookup(name)
+		if v == nil && this.is_const(name) {
+			this.warn("const-assignment", Code_Const_Assignment, "erro msg25", []string{name}, base.Line, base.Col)
+		}
+		if v != nil && target.Tp == "get attr" {
+			v.used = true
+		}
+	case "create local":
+		target := node.Value[0].(Node)
+		if target.Tp == "=" {
+			this.node(target.Value[1])
+			target = target.Value[0].(Node)
+		}
+		this.targets(target, true)
+	case "create global":
+		target := node.Value[0].(Node)
+		if target.Tp == "=" {
+			this.node(target.Value[1])
+			target = target.Value[0].(Node)
+		}
+		if this.functions > 0 {
+			for _, name := range target_names(target) {
+				this.warn("implicit-global", Code_Implicit_Global, "erro msg29", []string{name}, node.Line, node.Col)
+			}
+		}
+	case "function", "generator":
+		this.functions++
+		this.begin()
+		for _, p := range node.Value[1].(Node).Value {
+			p := p.(Node)
+			if p.Tp == "=" {
+				this.node(p.Value[1])
+				p = p.Value[0].(Node)
+			}
+			if p.Tp == "spread" {
+				p = p.Value[0].(Node)
+			}
+			this.targets(p, false)
+		}
+		for _, v := range this.scopes[len(this.scopes)-1].order {
+			v.param = true
+		}
+		this.block(node.Value[2].(Node).Value)
+		this.end()
+		this.functions--
+	case "if":
+		this.node(node.Value[0])
+		this.begin()
+		this.block(node.Value[1].(Node).Value)
+		this.end()
+	case "for":
+		this.node(node.Value[1])
+		this.begin()
+		this.targets(node.Value[0].(Node), false)
+		this.block(node.Value[2].(Node).Value)
+		this.end()
+	case "==":
+		if always_false(node.Value[0].(Node), node.Value[1].(Node)) {
+			line, col := node_start(node)
+			this.warn("always-false", Code_Always_False, "erro msg28", nil, line, col)
+		}
+		this.node(node.Value[0])
+		this.node(node.Value[1])
+	default:
+		for _, c := range node.Value {
+			this.node(c)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package kll
+
+import (
+	"fmt"
+	"testing"
+)
+
+// lint_findings lists the warnings of Lint as code@line:col.
+func lint_findings(src string, config Lint_Config) []string {
+	inter := Interpreter{Locale: "en"}
+	inter.Init()
+	re := []string{}
+	for _, e := range inter.Lint(src, config) {
+		re = append(re, fmt.Sprintf("%s@%d:%d", e.code, e.line, e.col))
+	}
+	return re
+}
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{name: "unused-variable", src: `function f() {
+	var a = 1
+	var _b = 2
+}
+f()`, want: []string{"KLL5001@2:6"}},
+		{name: "shadowed-variable", src: `var x = 1
+function f() {
+	var x = 2
+	return x
+}
+f()`, want: []string{"KLL5002@3:6"}},
+		{name: "shadowed by a later declaration", src: `function f() {
+	for i in 0..1 {
+		console.log(i)
+	}
+}
+var i = 0
+f()`, want: []string{}},
+		{name: "redeclared parameter", src: `function f(a) {
+	var a = 2
+	return a
+}
+f(1)`, want: []string{"KLL5002@2:6"}},
+		{name: "const-assignment", src: `Math = 1
+Math.pi = 3`, want: []string{"KLL5003@1:1", "KLL5003@2:1"}},
+		{name: "unreachable-code", src: `function f() {
+	return 1
+	console.log(2)
+}
+f()`, want: []string{"KLL5004@3:2"}},
+		{name: "not-callable", src: `var a = 1
+"a"(a)
+[1](a)`, want: []string{"KLL5005@2:1", "KLL5005@3:1"}},
+		{name: "always-false", src: `var a = 1
+if 1 == 2 {
+}
+if a == [] {
+}`, want: []string{"KLL5006@2:4", "KLL5006@4:4"}},
+		{name: "implicit-global", src: `global g = 1
+function f() {
+	global h = 2
+}
+f()`, want: []string{"KLL5007@3:2"}},
+	}
+	for _, test := range tests {
+		got := lint_findings(test.src, Lint_Config{})
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	if len(Lint_Rules) != 7 {
+		t.Errorf("there are %d rules, update the tests", len(Lint_Rules))
+	}
+}
+
+func TestLintIgnores(t *testing.T) {
+	var l Lexer
+	tokens, _ := l.Tokenizer(`var a = 1 // lint:ignore
+// lint:ignore always-false, not-callable
+var b = 2
+// lint:ignore-file unused-variable
+var c = 3 // lint:ignored is not a directive
+`)
+	got := lint_ignores(tokens)
+	want := map[uint64][]string{1: {}, 3: {"always-false", "not-callable"}, 0: {"unused-variable"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		src    string
+		config Lint_Config
+		want   []string
+	}{
+		{name: "same line", src: `1 == 2 // lint:ignore`, want: []string{}},
+		{name: "previous line", src: `// lint:ignore
+1 == 2
+1 == 3`, want: []string{"KLL5006@3:1"}},
+		{name: "named rule", src: `1 == 2 // lint:ignore always-false
+1 == 3 // lint:ignore not-callable`, want: []string{"KLL5006@2:1"}},
+		{name: "file", src: `// lint:ignore-file always-false
+1 == 2
+function f() {
+	var a = 1
+}
+f()`, want: []string{"KLL5001@4:6"}},
+		{name: "disabled", src: `1 == 2
+function f() {
+	var a = 1
+}
+f()`, config: Lint_Config{Disabled: map[string]bool{"unused-variable": true}}, want: []string{"KLL5006@1:1"}},
+	}
+	for _, test := range tests {
+		got := lint_findings(test.src, test.config)
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
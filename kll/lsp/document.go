@@ -0,0 +1,414 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"github.com/kaklikOf13/kll"
+	"github.com/kaklikOf13/kll/ast"
+)
+
+// symbol is a name declared by the script.
+type symbol struct {
+	name string
+	// kind is function, generator, var, global, parameter or for.
+	kind string
+	// pos is where the name is written in the declaration.
+	pos kll.Position
+	// end is the end of the declaration, the } of functions.
+	end      kll.Position
+	detail   string
+	children []*symbol
+}
+
+// scope is where the names declared in it can be used, from start to end.
+type scope struct {
+	parent     *scope
+	names      map[string]*symbol
+	start, end kll.Position
+}
+
+// document is an open file and what the server knows of it.
+type document struct {
+	text   string
+	lines  []string
+	tokens []kll.Token
+	// symbols are the declarations at the top of the file, with the ones
+	// inside functions as their children.
+	symbols []*symbol
+	// refs are the symbols the names of the file refer to, by the position
+	// of the name.
+	refs   map[kll.Position]*symbol
+	scopes []*scope
+	// closes maps each { to the position after the } closing it.
+	closes map[kll.Position]kll.Position
+	// names are the positions of the names of the function declarations,
+	// by the position of the function keyword.
+	names map[kll.Position]kll.Position
+}
+
+func create_document(text string) *document {
+	re := &document{text: text, lines: strings.Split(text, "\n"), refs: map[kll.Position]*symbol{}, closes: map[kll.Position]kll.Position{}, names: map[kll.Position]kll.Position{}}
+	var l kll.Lexer
+	re.tokens, _ = l.Tokenizer(text)
+	open := []kll.Position{}
+	for i, t := range re.tokens {
+		switch t.Type() {
+		case "{":
+			open = append(open, t.Span().Start)
+		case "}":
+			if len(open) > 0 {
+				re.closes[open[len(open)-1]] = t.Span().End
+				open = open[:len(open)-1]
+			}
+		case "function":
+			j := i + 1
+			if j < len(re.tokens) && re.tokens[j].Type() == "*" {
+				j++
+			}
+			if j < len(re.tokens) && re.tokens[j].Type() == "var" {
+				re.names[t.Span().Start] = re.tokens[j].Span().Start
+			}
+		}
+	}
+	// the nodes of a script with syntax errors are the statements that
+	// could still be parsed
+	var p kll.Parser
+	nodes, _ := p.Parse(text)
+	a := &analyzer{doc: re}
+	a.file(ast.From_Nodes(nodes))
+	return re
+}
+
+// position converts an LSP position, whose character counts UTF-16 units,
+// to a kll one.
+func (this *document) position(p position) kll.Position {
+	re := kll.Position{Line: uint64(p.Line) + 1, Col: 1}
+	if p.Line < 0 || p.Line >= len(this.lines) {
+		return re
+	}
+	units := 0
+	for _, r := range this.lines[p.Line] {
+		if units >= p.Character {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+		re.Col++
+	}
+	return re
+}
+func (this *document) lsp_position(p kll.Position) position {
+	re := position{Line: int(p.Line) - 1}
+	if p.Line == 0 {
+		return position{}
+	}
+	if re.Line >= len(this.lines) {
+		return re
+	}
+	runes := []rune(this.lines[re.Line])
+	n := int(p.Col) - 1
+	if n > len(runes) {
+		n = len(runes)
+	}
+	if n > 0 {
+		re.Character = len(utf16.Encode(runes[:n]))
+	}
+	return re
+}
+
+// name_range is the range of the name at p.
+func (this *document) name_range(p kll.Position, name string) lsp_range {
+	return lsp_range{Start: this.lsp_position(p), End: this.lsp_position(kll.Position{Line: p.Line, Col: p.Col + uint64(len([]rune(name)))})}
+}
+
+// token_at returns the index of the token at p, or -1.
+func (this *document) token_at(p kll.Position) int {
+	for i, t := range this.tokens {
+		span := t.Span()
+		if t.Type() == "new line" || t.Type() == "comment" {
+			continue
+		}
+		if span.Start.Line == p.Line && span.Start.Col <= p.Col && (span.End.Line > p.Line || p.Col < span.End.Col || t.Type() == "var" && p.Col == span.End.Col) {
+			return i
+		}
+	}
+	return -1
+}
+
+// path returns the names of the attribute path ending at the name token
+// i, as [console, log] for console.log.
+func (this *document) path(i int) []string {
+	re := []string{this.tokens[i].Value().Re_string("")}
+	for i >= 2 && this.tokens[i-1].Type() == "." && this.tokens[i-2].Type() == "var" {
+		i -= 2
+		re = append([]string{this.tokens[i].Value().Re_string("")}, re...)
+	}
+	if i >= 1 && this.tokens[i-1].Type() == "." {
+		return nil
+	}
+	return re
+}
+
+// visible lists the symbols that can be used at p.
+func (this *document) visible(p kll.Position) []*symbol {
+	re := []*symbol{}
+	seen := map[string]bool{}
+	// the inner scopes come after the ones containing them
+	for i := len(this.scopes) - 1; i >= 0; i-- {
+		s := this.scopes[i]
+		if !in_range(p, s.start, s.end) {
+			continue
+		}
+		for name, sym := range s.names {
+			if !seen[name] {
+				seen[name] = true
+				re = append(re, sym)
+			}
+		}
+	}
+	return re
+}
+
+func before(a kll.Position, b kll.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+}
+func in_range(p kll.Position, start kll.Position, end kll.Position) bool {
+	return !before(p, start) && (end.Line == 0 || before(p, end))
+}
+
+// analyzer walks the tree finding the declarations and what each name
+// refers to, with the scopes the resolver gives them.
+type analyzer struct {
+	doc   *document
+	scope *scope
+	// function is the symbol of the function being walked, nil at the top.
+	function *symbol
+}
+
+func pos(p ast.Pos) kll.Position {
+	return kll.Position{Line: p.Line, Col: p.Col}
+}
+func (this *analyzer) file(file *ast.File) {
+	this.push(kll.Position{Line: 1, Col: 1}, kll.Position{})
+	ast.Inspect(file, func(n ast.Node) bool {
+		if d, ok := n.(*ast.VarDecl); ok && d.Global {
+			for _, id := range target_idents(d.Target) {
+				this.declare(id.Name, pos(id.Pos), "global", "global "+id.Name)
+			}
+		}
+		return n != nil
+	})
+	this.block(file.Stmts)
+}
+func (this *analyzer) push(start kll.Position, end kll.Position) {
+	this.scope = &scope{parent: this.scope, names: map[string]*symbol{}, start: start, end: end}
+	this.doc.scopes = append(this.doc.scopes, this.scope)
+}
+func (this *analyzer) pop() {
+	this.scope = this.scope.parent
+}
+
+// block_end is the position after the } of b.
+func (this *analyzer) block_end(b *ast.Block) kll.Position {
+	return this.doc.closes[pos(b.Pos)]
+}
+
+func (this *analyzer) declare(name string, at kll.Position, kind string, detail string) *symbol {
+	s := this.scope
+	if kind == "global" {
+		for s.parent != nil {
+			s = s.parent
+		}
+	}
+	if sym, ok := s.names[name]; ok {
+		this.doc.refs[at] = sym
+		return sym
+	}
+	sym := &symbol{name: name, kind: kind, pos: at, end: at, detail: detail}
+	s.names[name] = sym
+	this.doc.refs[at] = sym
+	switch kind {
+	case "function", "generator", "var", "global":
+		if this.function != nil && kind != "global" {
+			this.function.children = append(this.function.children, sym)
+		} else {
+			this.doc.symbols = append(this.doc.symbols, sym)
+		}
+	}
+	return sym
+}
+func (this *analyzer) ref(id *ast.Ident) {
+	for s := this.scope; s != nil; s = s.parent {
+		if sym, ok := s.names[id.Name]; ok {
+			this.doc.refs[pos(id.Pos)] = sym
+			return
+		}
+	}
+}
+
+func target_idents(target ast.Node) []*ast.Ident {
+	switch t := target.(type) {
+	case *ast.Ident:
+		return []*ast.Ident{t}
+	case *ast.SpreadExpr:
+		return target_idents(t.X)
+	case *ast.ArrayLit:
+		re := []*ast.Ident{}
+		for _, e := range t.Elems {
+			re = append(re, target_idents(e)...)
+		}
+		return re
+	}
+	return nil
+}
+
+// kind_of tells the kind of value node evaluates to, when it can be known
+// without running it.
+func kind_of(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.Literal:
+		return n.Value.VType()
+	case *ast.ArrayLit:
+		return "Array"
+	case *ast.RangeExpr:
+		return "Range"
+	case *ast.FuncLit:
+		return "Function"
+	case *ast.ParenExpr:
+		return kind_of(n.X)
+	}
+	return ""
+}
+func var_detail(id *ast.Ident, value ast.Node) string {
+	re := "var " + id.Name
+	if kind := kind_of(value); kind != "" {
+		re += ": " + kind
+	}
+	return re
+}
+func func_detail(f *ast.FuncLit) string {
+	re := "function"
+	if f.Generator {
+		re += "*"
+	}
+	if f.Name != "" {
+		re += " " + f.Name
+	}
+	params := []string{}
+	for _, p := range f.Params {
+		switch p := p.(type) {
+		case *ast.Ident:
+			params = append(params, p.Name)
+		case *ast.AssignExpr:
+			if id, ok := p.Target.(*ast.Ident); ok {
+				params = append(params, id.Name+" = ...")
+			}
+		case *ast.SpreadExpr:
+			if id, ok := p.X.(*ast.Ident); ok {
+				params = append(params, "..."+id.Name)
+			}
+		}
+	}
+	return re + "(" + strings.Join(params, ", ") + ")"
+}
+
+// hoist declares the variables and named functions of a block before
+// walking it, as the resolver does.
+func (this *analyzer) hoist(stmts []ast.Node) {
+	for _, s := range stmts {
+		switch n := s.(type) {
+		case *ast.VarDecl:
+			if n.Global {
+				continue
+			}
+			for _, id := range target_idents(n.Target) {
+				this.declare(id.Name, pos(id.Pos), "var", var_detail(id, n.Value))
+			}
+		case *ast.FuncLit:
+			this.function_name(n)
+		}
+	}
+}
+func (this *analyzer) function_name(f *ast.FuncLit) {
+	if f.Name == "" {
+		return
+	}
+	at, ok := this.doc.names[pos(f.Pos)]
+	if !ok {
+		at = pos(f.Pos)
+	}
+	kind := "function"
+	if f.Generator {
+		kind = "generator"
+	}
+	sym := this.declare(f.Name, at, kind, func_detail(f))
+	if end := this.block_end(f.Body); end.Line != 0 {
+		sym.end = end
+	}
+}
+func (this *analyzer) block(stmts []ast.Node) {
+	this.hoist(stmts)
+	for _, s := range stmts {
+		this.node(s)
+	}
+}
+
+func (this *analyzer) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Ident:
+		this.ref(n)
+	case *ast.VarDecl:
+		if n.Value != nil {
+			this.node(n.Value)
+		}
+		kind := "var"
+		if n.Global {
+			kind = "global"
+		}
+		for _, id := range target_idents(n.Target) {
+			if n.Global {
+				this.declare(id.Name, pos(id.Pos), kind, "global "+id.Name)
+			} else {
+				this.declare(id.Name, pos(id.Pos), kind, var_detail(id, n.Value))
+			}
+		}
+	case *ast.FuncLit:
+		this.function_name(n)
+		outer := this.function
+		if n.Name != "" {
+			this.function = this.doc.refs[this.doc.names[pos(n.Pos)]]
+		}
+		this.push(pos(n.Pos), this.block_end(n.Body))
+		for _, p := range n.Params {
+			if a, ok := p.(*ast.AssignExpr); ok {
+				this.node(a.Value)
+				p = a.Target
+			}
+			for _, id := range target_idents(p) {
+				this.declare(id.Name, pos(id.Pos), "parameter", "(parametro) "+id.Name)
+			}
+		}
+		this.block(n.Body.Stmts)
+		this.pop()
+		this.function = outer
+	case *ast.IfStmt:
+		this.node(n.Cond)
+		this.push(pos(n.Body.Pos), this.block_end(n.Body))
+		this.block(n.Body.Stmts)
+		this.pop()
+	case *ast.ForStmt:
+		this.node(n.Iter)
+		this.push(pos(n.Pos), this.block_end(n.Body))
+		for _, id := range target_idents(n.Target) {
+			this.declare(id.Name, pos(id.Pos), "for", "var "+id.Name)
+		}
+		this.block(n.Body.Stmts)
+		this.pop()
+	case *ast.KeywordArg:
+		this.node(n.Value)
+	default:
+		for _, c := range ast.Children(node) {
+			this.node(c)
+		}
+	}
+}
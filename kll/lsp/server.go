@@ -0,0 +1,484 @@
+// Package lsp is a Language Server Protocol server for kll, spoken as
+// JSON-RPC over a pair of streams, usually the standard input and output
+// of kll lsp. It publishes the errors of Check and the warnings of Lint
+// of the open files, and answers hover, definition, document symbols,
+// completion and formatting requests.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kaklikOf13/kll"
+	"github.com/kaklikOf13/kll/format"
+)
+
+// Server keeps the open documents of one client. Locale is the language
+// of the messages of the diagnostics.
+type Server struct {
+	Locale string
+	// Lint_Config chooses the warnings published with the errors.
+	Lint_Config kll.Lint_Config
+
+	out         io.Writer
+	docs        map[string]*document
+	inter       *kll.Interpreter
+	initialized bool
+	shutdown    bool
+}
+
+// Err_Exit_Without_Shutdown is returned by Serve when the client sent
+// exit before shutdown; the process should end with status 1.
+var Err_Exit_Without_Shutdown = errors.New("lsp: exit without shutdown")
+
+type message struct {
+	Id     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+type rpc_error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	code_parse_error      = -32700
+	code_invalid_params   = -32602
+	code_method_not_found = -32601
+	code_internal_error   = -32603
+	code_not_initialized  = -32002
+)
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+type lsp_range struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+type location struct {
+	Uri   string    `json:"uri"`
+	Range lsp_range `json:"range"`
+}
+type text_document struct {
+	Uri  string `json:"uri"`
+	Text string `json:"text"`
+}
+type position_params struct {
+	Text_Document text_document `json:"textDocument"`
+	Position      position      `json:"position"`
+}
+type diagnostic struct {
+	Range    lsp_range `json:"range"`
+	Severity int       `json:"severity"`
+	Code     string    `json:"code,omitempty"`
+	Source   string    `json:"source"`
+	Message  string    `json:"message"`
+}
+type document_symbol struct {
+	Name            string            `json:"name"`
+	Detail          string            `json:"detail,omitempty"`
+	Kind            int               `json:"kind"`
+	Range           lsp_range         `json:"range"`
+	Selection_Range lsp_range         `json:"selectionRange"`
+	Children        []document_symbol `json:"children,omitempty"`
+}
+type completion_item struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+type text_edit struct {
+	Range    lsp_range `json:"range"`
+	New_Text string    `json:"newText"`
+}
+
+// kinds of the protocol
+const (
+	severity_error   = 1
+	severity_warning = 2
+
+	symbol_function = 12
+	symbol_variable = 13
+
+	completion_function = 3
+	completion_variable = 6
+	completion_module   = 9
+	completion_keyword  = 14
+)
+
+// Serve answers the messages read from in, writing to out, until the
+// client sends exit or in ends.
+func (this *Server) Serve(in io.Reader, out io.Writer) error {
+	this.out = out
+	this.docs = map[string]*document{}
+	this.inter = &kll.Interpreter{Locale: this.Locale}
+	this.inter.Init()
+	reader := bufio.NewReader(in)
+	for {
+		body, err := read_message(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			this.reply(nil, nil, &rpc_error{Code: code_parse_error, Message: err.Error()})
+			continue
+		}
+		if msg.Method == "exit" {
+			if !this.shutdown {
+				return Err_Exit_Without_Shutdown
+			}
+			return nil
+		}
+		result, e := this.safe_handle(msg)
+		if msg.Id != nil {
+			this.reply(msg.Id, result, e)
+		}
+	}
+}
+
+// safe_handle is handle, answering with an error instead of ending the
+// server when it panics.
+func (this *Server) safe_handle(msg message) (result any, err *rpc_error) {
+	defer func() {
+		if e := recover(); e != nil {
+			result, err = nil, &rpc_error{Code: code_internal_error, Message: fmt.Sprint(e)}
+		}
+	}()
+	return this.handle(msg)
+}
+
+// read_message reads the body of a message, after its Content-Length
+// header.
+func read_message(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length == -1 {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("lsp: bad Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("lsp: message without Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return body, err
+}
+func (this *Server) write(v any) {
+	body, _ := json.Marshal(v)
+	fmt.Fprintf(this.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+func (this *Server) reply(id *json.RawMessage, result any, err *rpc_error) {
+	re := map[string]any{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		re["error"] = err
+	} else {
+		re["result"] = result
+	}
+	this.write(re)
+}
+func (this *Server) notify(method string, params any) {
+	this.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (this *Server) handle(msg message) (any, *rpc_error) {
+	if !this.initialized && msg.Method != "initialize" {
+		if msg.Id == nil {
+			return nil, nil
+		}
+		return nil, &rpc_error{Code: code_not_initialized, Message: "the server was not initialized"}
+	}
+	var params struct {
+		position_params
+		Content_Changes []text_document `json:"contentChanges"`
+	}
+	if len(msg.Params) > 0 {
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpc_error{Code: code_invalid_params, Message: err.Error()}
+		}
+	}
+	uri := params.Text_Document.Uri
+	switch msg.Method {
+	case "initialize":
+		this.initialized = true
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":           1,
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"documentSymbolProvider":     true,
+				"documentFormattingProvider": true,
+				"completionProvider":         map[string]any{"triggerCharacters": []string{"."}},
+			},
+			"serverInfo": map[string]any{"name": "kll"},
+		}, nil
+	case "shutdown":
+		this.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		this.open(uri, params.Text_Document.Text)
+	case "textDocument/didChange":
+		if n := len(params.Content_Changes); n > 0 {
+			this.open(uri, params.Content_Changes[n-1].Text)
+		}
+	case "textDocument/didClose":
+		delete(this.docs, uri)
+		this.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": []diagnostic{}})
+	case "textDocument/hover":
+		return this.hover(uri, params.Position), nil
+	case "textDocument/definition":
+		return this.definition(uri, params.Position), nil
+	case "textDocument/documentSymbol":
+		return this.symbols(uri), nil
+	case "textDocument/completion":
+		return this.completion(uri, params.Position), nil
+	case "textDocument/formatting":
+		return this.formatting(uri), nil
+	default:
+		if msg.Id != nil {
+			return nil, &rpc_error{Code: code_method_not_found, Message: "method not found: " + msg.Method}
+		}
+	}
+	return nil, nil
+}
+
+// open keeps the text of a document and publishes its diagnostics.
+func (this *Server) open(uri string, text string) {
+	doc := create_document(text)
+	this.docs[uri] = doc
+	errs := this.inter.Check(text)
+	if len(errs) == 0 {
+		errs = this.inter.Lint(text, this.Lint_Config)
+	}
+	list := []diagnostic{}
+	for _, e := range errs {
+		d := e.Diagnostic()
+		severity := severity_error
+		if d.Severity == kll.Severity_Warning {
+			severity = severity_warning
+		}
+		r := lsp_range{Start: doc.lsp_position(d.Span.Start), End: doc.lsp_position(d.Span.End)}
+		if d.Span.End == d.Span.Start {
+			// errors that only have a start cover the token there
+			r.End = r.Start
+			r.End.Character++
+			if i := doc.token_at(d.Span.Start); i >= 0 {
+				r.End = doc.lsp_position(doc.tokens[i].Span().End)
+			}
+		}
+		list = append(list, diagnostic{Range: r, Severity: severity, Code: string(d.Code), Source: "kll", Message: d.Message})
+	}
+	this.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": list})
+}
+
+// global is the value of the built-in global at the start of path, and
+// of its attributes after it, nil when there is none.
+func (this *Server) global(path []string) kll.Value {
+	if len(path) == 0 || !has(this.inter.Globals.Keys(), path[0]) {
+		return nil
+	}
+	v := this.inter.Globals.On_get_attr(path[0])
+	for _, name := range path[1:] {
+		names, ok := kll.Attr_Names(v)
+		if !ok || !has(names, name) {
+			return nil
+		}
+		v = v.On_get_attr(name)
+	}
+	return v
+}
+func has(list []string, name string) bool {
+	for _, v := range list {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// name_at returns the name token at p of the document, with the symbol it
+// refers to, if the script declares it.
+func (this *Server) name_at(uri string, p position) (*document, int, *symbol) {
+	doc, ok := this.docs[uri]
+	if !ok {
+		return nil, -1, nil
+	}
+	i := doc.token_at(doc.position(p))
+	if i < 0 || doc.tokens[i].Type() != "var" {
+		return doc, -1, nil
+	}
+	return doc, i, doc.refs[doc.tokens[i].Span().Start]
+}
+func (this *Server) hover(uri string, p position) any {
+	doc, i, sym := this.name_at(uri, p)
+	if i < 0 {
+		return nil
+	}
+	txt := ""
+	if sym != nil {
+		txt = sym.detail
+	} else if path := doc.path(i); path != nil {
+		// a path from a name of the script can't be known without running
+		root := doc.tokens[i-2*(len(path)-1)]
+		if doc.refs[root.Span().Start] == nil {
+			if v := this.global(path); v != nil {
+				txt = strings.Join(path, ".") + ": " + v.VType()
+			}
+		}
+	}
+	if txt == "" {
+		return nil
+	}
+	start := doc.tokens[i].Span()
+	return map[string]any{
+		"contents": map[string]any{"kind": "markdown", "value": "```kll\n" + txt + "\n```"},
+		"range":    lsp_range{Start: doc.lsp_position(start.Start), End: doc.lsp_position(start.End)},
+	}
+}
+func (this *Server) definition(uri string, p position) any {
+	doc, _, sym := this.name_at(uri, p)
+	if sym == nil {
+		return nil
+	}
+	return location{Uri: uri, Range: doc.name_range(sym.pos, sym.name)}
+}
+func (this *Server) symbols(uri string) any {
+	doc, ok := this.docs[uri]
+	if !ok {
+		return nil
+	}
+	var list func(symbols []*symbol) []document_symbol
+	list = func(symbols []*symbol) []document_symbol {
+		sort.SliceStable(symbols, func(i, j int) bool {
+			return before(symbols[i].pos, symbols[j].pos)
+		})
+		re := []document_symbol{}
+		for _, s := range symbols {
+			kind := symbol_variable
+			r := doc.name_range(s.pos, s.name)
+			if s.kind == "function" || s.kind == "generator" {
+				kind = symbol_function
+				r.End = doc.lsp_position(s.end)
+			}
+			re = append(re, document_symbol{Name: s.name, Detail: s.detail, Kind: kind, Range: r, Selection_Range: doc.name_range(s.pos, s.name), Children: list(s.children)})
+		}
+		return re
+	}
+	return list(doc.symbols)
+}
+
+// completion lists the names that can replace the one before p: the
+// variables of the script, the built-in globals and the keywords, or the
+// attributes of a built-in global after a dot.
+func (this *Server) completion(uri string, p position) any {
+	doc, ok := this.docs[uri]
+	if !ok {
+		return []completion_item{}
+	}
+	at := doc.position(p)
+	line := []rune(doc.lines[at.Line-1])
+	start := int(at.Col) - 1
+	if start > len(line) {
+		start = len(line)
+	}
+	end := start
+	for start > 0 && (line[start-1] == '.' || strings.ContainsRune(names, line[start-1])) {
+		start--
+	}
+	path := strings.Split(string(line[start:end]), ".")
+	prefix := path[len(path)-1]
+	re := []completion_item{}
+	add := func(name string, kind int, detail string) {
+		if strings.HasPrefix(name, prefix) {
+			re = append(re, completion_item{Label: name, Kind: kind, Detail: detail})
+		}
+	}
+	if len(path) > 1 {
+		v := this.global(path[:len(path)-1])
+		if v == nil {
+			return re
+		}
+		attrs, _ := kll.Attr_Names(v)
+		for _, name := range attrs {
+			a := v.On_get_attr(name)
+			add(name, value_kind(a), a.VType())
+		}
+		return re
+	}
+	seen := map[string]bool{}
+	for _, s := range doc.visible(at) {
+		seen[s.name] = true
+		kind := completion_variable
+		if s.kind == "function" || s.kind == "generator" {
+			kind = completion_function
+		}
+		add(s.name, kind, s.detail)
+	}
+	for _, name := range this.inter.Globals.Keys() {
+		if !seen[name] {
+			v := this.inter.Globals.On_get_attr(name)
+			add(name, value_kind(v), v.VType())
+		}
+	}
+	for _, k := range kll.Keywords {
+		add(k, completion_keyword, "")
+	}
+	sort.SliceStable(re, func(i, j int) bool {
+		return re[i].Label < re[j].Label
+	})
+	return re
+}
+
+// names are the characters of variable names.
+const names = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ_0123456789"
+
+func value_kind(v kll.Value) int {
+	switch v.VType() {
+	case "Function", "GoFunction":
+		return completion_function
+	case "Object":
+		return completion_module
+	}
+	return completion_variable
+}
+
+// formatting replaces the whole document with the text of format.Source,
+// or changes nothing when it has syntax errors.
+func (this *Server) formatting(uri string) any {
+	doc, ok := this.docs[uri]
+	if !ok {
+		return nil
+	}
+	re, err := format.Source(doc.text)
+	if err != nil || re == doc.text {
+		return []text_edit{}
+	}
+	last := len(doc.lines)
+	end := doc.lsp_position(kll.Position{Line: uint64(last), Col: uint64(len([]rune(doc.lines[last-1]))) + 1})
+	return []text_edit{{Range: lsp_range{End: end}, New_Text: re}}
+}
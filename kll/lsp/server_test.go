@@ -0,0 +1,267 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+const uri = "file:///t.kll"
+
+// session sends msgs to a Server and returns what it answered: the
+// responses by id and the notifications in order.
+func session(t *testing.T, msgs ...map[string]any) (map[float64]map[string]any, []map[string]any, error) {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, m := range msgs {
+		m["jsonrpc"] = "2.0"
+		body, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	s := &Server{Locale: "en"}
+	err := s.Serve(&in, &out)
+	responses := map[float64]map[string]any{}
+	notifications := []map[string]any{}
+	r := bufio.NewReader(&out)
+	for {
+		body, e := read_message(r)
+		if e != nil {
+			break
+		}
+		var m map[string]any
+		if e := json.Unmarshal(body, &m); e != nil {
+			t.Fatal(e)
+		}
+		if id, ok := m["id"].(float64); ok {
+			responses[id] = m
+		} else {
+			notifications = append(notifications, m)
+		}
+	}
+	return responses, notifications, err
+}
+
+func request(id int, method string, params any) map[string]any {
+	return map[string]any{"id": id, "method": method, "params": params}
+}
+func notification(method string, params any) map[string]any {
+	return map[string]any{"method": method, "params": params}
+}
+func initialize() map[string]any {
+	return request(0, "initialize", map[string]any{"capabilities": map[string]any{}})
+}
+func did_open(text string) map[string]any {
+	return notification("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "kll", "version": 1, "text": text}})
+}
+func at(line int, character int) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": map[string]any{"line": line, "character": character}}
+}
+
+// get follows a path of keys and indexes through a decoded JSON value.
+func get(v any, path ...any) any {
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = m[p]
+		case int:
+			l, ok := v.([]any)
+			if !ok || p >= len(l) {
+				return nil
+			}
+			v = l[p]
+		}
+	}
+	return v
+}
+
+const script = `var total = 0
+function soma(a, b) {
+    var r = a + b
+    return r
+}
+console.log(soma(total, 2), Math.pi)
+con
+Math.
+`
+
+func TestInitialize(t *testing.T) {
+	responses, _, err := session(t,
+		request(1, "textDocument/hover", at(0, 0)),
+		initialize(),
+		request(2, "bogus/method", nil),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := get(responses[1], "error", "code"); code != float64(code_not_initialized) {
+		t.Errorf("before initialize: got error %v, want %d", code, code_not_initialized)
+	}
+	for _, capability := range []string{"hoverProvider", "definitionProvider", "documentSymbolProvider", "documentFormattingProvider"} {
+		if get(responses[0], "result", "capabilities", capability) != true {
+			t.Errorf("the capability %s is missing", capability)
+		}
+	}
+	if code := get(responses[2], "error", "code"); code != float64(code_method_not_found) {
+		t.Errorf("unknown method: got error %v, want %d", code, code_method_not_found)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		code     string
+		severity float64
+		line     float64
+	}{
+		{name: "syntax error", text: "var a = 1\nvar y = 1 +\n", code: "KLL1005", severity: severity_error, line: 1},
+		{name: "dangling operator", text: "var y = 1 + ; console.log(y)\n", code: "KLL1005", severity: severity_error, line: 0},
+		{name: "undefined variable", text: "console.log(nada)\n", code: "KLL2001", severity: severity_error, line: 0},
+		{name: "lint warning", text: "function f() {\n    var usado = 1\n}\nf()\n", code: "KLL5001", severity: severity_warning, line: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, notifications, err := session(t, initialize(), did_open(test.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(notifications) != 1 || notifications[0]["method"] != "textDocument/publishDiagnostics" {
+				t.Fatalf("got %v, want the diagnostics", notifications)
+			}
+			d := get(notifications[0], "params", "diagnostics", 0)
+			if get(d, "code") != test.code || get(d, "severity") != test.severity || get(d, "range", "start", "line") != test.line {
+				t.Fatalf("got %v, want %s with severity %v at line %v", d, test.code, test.severity, test.line)
+			}
+		})
+	}
+	_, notifications, err := session(t, initialize(), did_open("var a = 1\nconsole.log(a)\n"),
+		notification("textDocument/didClose", map[string]any{"textDocument": map[string]any{"uri": uri}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range notifications {
+		if l := get(n, "params", "diagnostics").([]any); len(l) != 0 {
+			t.Errorf("notification %d: got %v, want no diagnostics", i, l)
+		}
+	}
+}
+
+func TestHoverAndDefinition(t *testing.T) {
+	responses, _, err := session(t, initialize(), did_open(script),
+		request(1, "textDocument/hover", at(5, 13)),
+		request(2, "textDocument/hover", at(5, 34)),
+		request(3, "textDocument/hover", at(0, 5)),
+		request(4, "textDocument/definition", at(5, 13)),
+		request(5, "textDocument/definition", at(3, 11)),
+		request(6, "textDocument/definition", at(5, 2)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hovers := map[float64]string{
+		1: "```kll\nfunction soma(a, b)\n```",
+		2: "```kll\nMath.pi: Number\n```",
+		3: "```kll\nvar total: Number\n```",
+	}
+	for id, want := range hovers {
+		if got := get(responses[id], "result", "contents", "value"); got != want {
+			t.Errorf("hover %v: got %v, want %q", id, got, want)
+		}
+	}
+	definitions := map[float64][2]float64{4: {1, 9}, 5: {2, 8}}
+	for id, want := range definitions {
+		start := get(responses[id], "result", "range", "start")
+		if get(start, "line") != want[0] || get(start, "character") != want[1] {
+			t.Errorf("definition %v: got %v, want %v", id, start, want)
+		}
+	}
+	if r := responses[6]["result"]; r != nil {
+		t.Errorf("definition of a built-in: got %v, want null", r)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	responses, _, err := session(t, initialize(), did_open(script),
+		request(1, "textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := responses[1]["result"]
+	if get(result, 0, "name") != "total" || get(result, 1, "name") != "soma" || get(result, 2) != nil {
+		t.Fatalf("got %v, want total and soma", result)
+	}
+	if get(result, 1, "kind") != float64(symbol_function) || get(result, 1, "range", "end", "line") != float64(4) {
+		t.Errorf("soma: got %v", get(result, 1))
+	}
+	if get(result, 1, "children", 0, "name") != "r" {
+		t.Errorf("the children of soma: got %v, want r", get(result, 1, "children"))
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	responses, _, err := session(t, initialize(), did_open(script),
+		request(1, "textDocument/completion", at(6, 3)),
+		request(2, "textDocument/completion", at(7, 5)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := func(id float64) map[string]bool {
+		re := map[string]bool{}
+		for _, item := range responses[id]["result"].([]any) {
+			re[get(item, "label").(string)] = true
+		}
+		return re
+	}
+	if got := labels(1); !got["console"] || got["total"] {
+		t.Errorf("con: got %v, want console and not total", got)
+	}
+	if got := labels(2); !got["pi"] || !got["floor"] || got["console"] {
+		t.Errorf("Math.: got %v, want the attributes of Math", got)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	responses, _, err := session(t, initialize(), did_open("var  x=1\n"),
+		request(1, "textDocument/formatting", map[string]any{"textDocument": map[string]any{"uri": uri}, "options": map[string]any{}}),
+		notification("textDocument/didChange", map[string]any{"textDocument": map[string]any{"uri": uri}, "contentChanges": []any{map[string]any{"text": "var y = 1 +\n"}}}),
+		request(2, "textDocument/formatting", map[string]any{"textDocument": map[string]any{"uri": uri}, "options": map[string]any{}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get(responses[1], "result", 0, "newText"); got != "var x = 1\n" {
+		t.Errorf("got %v, want the formatted text", got)
+	}
+	if got := responses[2]["result"].([]any); len(got) != 0 {
+		t.Errorf("a script with errors: got %v, want no edits", got)
+	}
+}
+
+func TestShutdownExit(t *testing.T) {
+	responses, _, err := session(t, initialize(), request(1, "shutdown", nil), notification("exit", nil), request(2, "shutdown", nil))
+	if err != nil {
+		t.Fatalf("exit after shutdown: got %v", err)
+	}
+	if _, ok := responses[1]; !ok {
+		t.Error("shutdown was not answered")
+	}
+	if _, ok := responses[2]; ok {
+		t.Error("the server answered after exit")
+	}
+	if _, _, err := session(t, initialize(), notification("exit", nil)); err != Err_Exit_Without_Shutdown {
+		t.Errorf("exit without shutdown: got %v, want %v", err, Err_Exit_Without_Shutdown)
+	}
+	if _, _, err := session(t, initialize()); err != nil {
+		t.Errorf("end of the input: got %v, want nil", err)
+	}
+}
@@ -0,0 +1,90 @@
+package kll
+
+// Optimize returns a copy of nodes, as returned by Parser.Parse, with the
+// arithmetic, string concatenation, comparisons and boolean logic between
+// literals folded into "value" nodes, the "()" grouping nodes replaced by
+// what they group and the if blocks whose condition is a false literal
+// removed. Folded nodes keep the Line and Col of the node they replace.
+func Optimize(nodes []Value) []Value {
+	return optimize_block(nodes, true)
+}
+
+// optimize_block drops the dead if statements of a block. The last node
+// of the module is kept as a null value since Eval returns it.
+func optimize_block(nodes []Value, module bool) []Value {
+	re := make([]Value, 0, len(nodes))
+	for i, v := range nodes {
+		node := optimize_node(v).(Node)
+		if v.(Node).Tp == "if" && node.Tp == "value" && (!module || i < len(nodes)-1) {
+			continue
+		}
+		re = append(re, node)
+	}
+	return re
+}
+
+// is_literal reports whether value is a "value" node holding one of the
+// immutable types folding may touch.
+func is_literal(value Value) bool {
+	node, ok := value.(Node)
+	if !ok || node.Tp != "value" {
+		return false
+	}
+	switch node.Value[0].VType() {
+	case "Number", "String", "Bool", "Null":
+		return true
+	}
+	return false
+}
+func optimize_node(value Value) Value {
+	node, ok := value.(Node)
+	if !ok {
+		return value
+	}
+	if node.Tp == "{}" {
+		return Create_Node(optimize_block(node.Value, false), node.Tp, node.Line, node.Col)
+	}
+	values := make([]Value, len(node.Value))
+	for i, v := range node.Value {
+		values[i] = optimize_node(v)
+	}
+	literal := func(v Value) Value {
+		return Create_Node([]Value{v}, "value", node.Line, node.Col)
+	}
+	switch node.Tp {
+	case "()":
+		return values[0]
+	case "inverse number":
+		if is_literal(values[0]) {
+			return literal(Create_Number(-values[0].(Node).Value[0].Re_number()))
+		}
+	case "if":
+		if is_literal(values[0]) && !values[0].(Node).Value[0].Re_bool() {
+			return literal(Create_Null())
+		}
+	case "+", "-", "*", "/", "==", "&&", "||", "in":
+		if !is_literal(values[0]) || !is_literal(values[1]) {
+			break
+		}
+		v1, v2 := values[0].(Node).Value[0], values[1].(Node).Value[0]
+		switch node.Tp {
+		case "+":
+			return literal(Sum(v1, v2))
+		case "-":
+			return literal(Sub(v1, v2))
+		case "*":
+			return literal(Mul(v1, v2))
+		case "/":
+			return literal(Div(v1, v2))
+		case "==":
+			return literal(Create_Bool(Equal(v1, v2)))
+		case "&&":
+			return literal(Create_Bool(v1.Re_bool() && v2.Re_bool()))
+		case "||":
+			return literal(Create_Bool(v1.Re_bool() || v2.Re_bool()))
+		case "in":
+			return literal(In(v2, v1))
+		}
+	}
+	return Create_Node(values, node.Tp, node.Line, node.Col)
+}
@@ -0,0 +1,112 @@
+package kll
+
+import "testing"
+
+func TestOptimizeFolds(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{source: `1 + 2 * 3`, want: "7"},
+		{source: `(4 / 2) - 1`, want: "1"},
+		{source: `-(2 + 3)`, want: "-5"},
+		{source: `"ab" + "c"`, want: "abc"},
+		{source: `"ab" * 2`, want: "abab"},
+		{source: `1 == 1`, want: "true"},
+		{source: `"a" == "b"`, want: "false"},
+		{source: `1 == 1 && "a" == "b"`, want: "false"},
+		{source: `1 == 2 || "a" == "a"`, want: "true"},
+		{source: `"b" in "abc"`, want: "true"},
+	}
+	for _, test := range tests {
+		nodes, err := (&Parser{}).Parse(test.source)
+		if err != nil {
+			t.Fatalf("%s: %v", test.source, err)
+		}
+		parsed := nodes[0].(Node)
+		node := Optimize(nodes)[0].(Node)
+		if node.Tp != "value" {
+			t.Errorf("%s: got a %q node, want a folded value", test.source, node.Tp)
+			continue
+		}
+		if got := node.Value[0].Re_string(""); got != test.want {
+			t.Errorf("%s: folded to %s, want %s", test.source, got, test.want)
+		}
+		if node.Line != parsed.Line || node.Col != parsed.Col {
+			t.Errorf("%s: folded node at %d:%d, want %d:%d", test.source, node.Line, node.Col, parsed.Line, parsed.Col)
+		}
+	}
+}
+
+func TestOptimizeKeepsVariables(t *testing.T) {
+	nodes, err := (&Parser{}).Parse("var x = 1\nx + (2 + 3)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := Optimize(nodes)[1].(Node)
+	if node.Tp != "+" {
+		t.Fatalf("got a %q node, want +", node.Tp)
+	}
+	if right := node.Value[1].(Node); right.Tp != "value" || right.Value[0].Re_string("") != "5" {
+		t.Errorf("the literal side was not folded: got %s", right.Re_string(""))
+	}
+}
+
+func TestOptimizeRemovesDeadBranches(t *testing.T) {
+	nodes, err := (&Parser{}).Parse(`if 1 == 2 {
+	console.log("never")
+}
+function f() {
+	if 1 == 2 {
+		return 1
+	}
+	if 1 == 1 {
+		return 2
+	}
+}
+if "a" == "b" {
+	3
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes = Optimize(nodes)
+	if len(nodes) != 2 {
+		t.Fatalf("got %d statements, want the function and the last if", len(nodes))
+	}
+	if tp := nodes[0].(Node).Tp; tp != "function" {
+		t.Errorf("the dead if was kept: the first statement is a %q node", tp)
+	}
+	body := nodes[0].(Node).Value[2].(Node).Value
+	if len(body) != 1 || body[0].(Node).Tp != "if" {
+		t.Errorf("the function body should only keep the live if, got %d statements", len(body))
+	}
+	// the last statement is the value of the module
+	if last := nodes[1].(Node); last.Tp != "value" || last.Value[0].VType() != "Null" {
+		t.Errorf("the last dead if should become null, got a %q node", last.Tp)
+	}
+}
+
+// TestOptimizeKeepsResults runs the scripts of the engine tests with and
+// without Optimize, which must give the same values and errors.
+func TestOptimizeKeepsResults(t *testing.T) {
+	for _, test := range engine_tests {
+		for _, vm := range []bool{false, true} {
+			results := [2]string{}
+			for i, optimize := range []bool{false, true} {
+				inter := Interpreter{Use_VM: vm, Optimize: optimize, Locale: "en"}
+				inter.Init()
+				locals := Create_Object(nil).(Object)
+				v, err := inter.Eval(test.source, &locals)
+				if err != nil {
+					results[i] = err.Error()
+				} else {
+					results[i] = v.Re_string("")
+				}
+			}
+			if results[0] != results[1] {
+				t.Errorf("%s, vm=%v: Optimize changed the result\nwithout: %s\nwith:    %s", test.name, vm, results[0], results[1])
+			}
+		}
+	}
+}
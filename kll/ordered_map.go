@@ -0,0 +1,134 @@
+package kll
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// OrderedMap is the backing store of Object: lookups stay O(1) through
+// index while entries keeps the order in which the keys were first set.
+// Deleted entries are left as holes and compacted once they dominate.
+type OrderedMap struct {
+	index   map[string]int
+	entries []ordered_entry
+	deleted int
+}
+type ordered_entry struct {
+	key     string
+	value   Variable
+	deleted bool
+}
+
+func Create_OrderedMap() *OrderedMap {
+	return &OrderedMap{index: make(map[string]int)}
+}
+func (this *OrderedMap) Get(key string) (Variable, bool) {
+	if i, ok := this.index[key]; ok {
+		return this.entries[i].value, true
+	}
+	return Variable{}, false
+}
+func (this *OrderedMap) Has(key string) bool {
+	_, ok := this.index[key]
+	return ok
+}
+func (this *OrderedMap) Set(key string, value Variable) {
+	if i, ok := this.index[key]; ok {
+		this.entries[i].value = value
+		return
+	}
+	this.index[key] = len(this.entries)
+	this.entries = append(this.entries, ordered_entry{key: key, value: value})
+}
+
+// Set_Value changes the Value of the Variable at key, or adds one.
+func (this *OrderedMap) Set_Value(key string, value Value) {
+	if i, ok := this.index[key]; ok {
+		this.entries[i].value.Value = value
+		return
+	}
+	this.Set(key, Variable{name: key, Value: value})
+}
+
+// set_at is Set_Value for the key at position i of entries, and returns
+// false when it is not there anymore.
+func (this *OrderedMap) set_at(i int, key string, value Value) bool {
+	if i < 0 || i >= len(this.entries) || this.entries[i].key != key {
+		return false
+	}
+	this.entries[i].value.Value = value
+	return true
+}
+func (this *OrderedMap) Delete(key string) bool {
+	i, ok := this.index[key]
+	if !ok {
+		return false
+	}
+	delete(this.index, key)
+	this.entries[i] = ordered_entry{deleted: true}
+	this.deleted++
+	if this.deleted > 16 && this.deleted*2 > len(this.entries) {
+		this.compact()
+	}
+	return true
+}
+func (this *OrderedMap) compact() {
+	entries := make([]ordered_entry, 0, len(this.index))
+	for _, e := range this.entries {
+		if !e.deleted {
+			this.index[e.key] = len(entries)
+			entries = append(entries, e)
+		}
+	}
+	this.entries = entries
+	this.deleted = 0
+}
+func (this *OrderedMap) Len() int {
+	return len(this.index)
+}
+func (this *OrderedMap) Keys() []string {
+	re := make([]string, 0, len(this.index))
+	for _, e := range this.entries {
+		if !e.deleted {
+			re = append(re, e.key)
+		}
+	}
+	return re
+}
+
+// Each walks the entries in insertion order until function returns false.
+func (this *OrderedMap) Each(function func(key string, value Variable) bool) {
+	for _, e := range this.entries {
+		if !e.deleted && !function(e.key, e.value) {
+			return
+		}
+	}
+}
+func (this *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	var err error
+	this.Each(func(key string, value Variable) bool {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		var k, v []byte
+		if k, err = json.Marshal(key); err != nil {
+			return false
+		}
+		if v, err = json.Marshal(value.Value); err != nil {
+			return false
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
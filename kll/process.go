@@ -0,0 +1,262 @@
+package kll
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Process is what scripts see of the process they run in, through the
+// process global. An Interpreter with no Process uses the real one; give
+// it a Virtual_Process, or your own, to choose what scripts can read and
+// change.
+type Process interface {
+	Args() []string
+	Getenv(name string) (string, bool)
+	Setenv(name string, value string) error
+	Unsetenv(name string) error
+	Env_Names() []string
+	Cwd() (string, error)
+	Pid() int
+	Platform() string
+}
+
+// os_process is the real process, with the arguments of the Interpreter.
+type os_process struct {
+	args []string
+}
+
+func (this os_process) Args() []string {
+	return this.args
+}
+func (this os_process) Getenv(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+func (this os_process) Setenv(name string, value string) error {
+	return os.Setenv(name, value)
+}
+func (this os_process) Unsetenv(name string) error {
+	return os.Unsetenv(name)
+}
+func (this os_process) Env_Names() []string {
+	re := []string{}
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); name != "" {
+			re = append(re, name)
+		}
+	}
+	sort.Strings(re)
+	return re
+}
+func (this os_process) Cwd() (string, error) {
+	return os.Getwd()
+}
+func (this os_process) Pid() int {
+	return os.Getpid()
+}
+func (this os_process) Platform() string {
+	return runtime.GOOS
+}
+
+// Virtual_Process is a Process that only exists in memory: scripts see
+// the fields and their changes to Env stay in it.
+type Virtual_Process struct {
+	Arguments []string
+	Env       map[string]string
+	Dir       string
+	Id        int
+	OS        string
+}
+
+func (this *Virtual_Process) Args() []string {
+	return this.Arguments
+}
+func (this *Virtual_Process) Getenv(name string) (string, bool) {
+	v, ok := this.Env[name]
+	return v, ok
+}
+func (this *Virtual_Process) Setenv(name string, value string) error {
+	if this.Env == nil {
+		this.Env = map[string]string{}
+	}
+	this.Env[name] = value
+	return nil
+}
+func (this *Virtual_Process) Unsetenv(name string) error {
+	delete(this.Env, name)
+	return nil
+}
+func (this *Virtual_Process) Env_Names() []string {
+	re := []string{}
+	for k := range this.Env {
+		re = append(re, k)
+	}
+	sort.Strings(re)
+	return re
+}
+func (this *Virtual_Process) Cwd() (string, error) {
+	return this.Dir, nil
+}
+func (this *Virtual_Process) Pid() int {
+	return this.Id
+}
+func (this *Virtual_Process) Platform() string {
+	return this.OS
+}
+
+// Env is process.env, the environment variables of a Process, used like a
+// Map whose keys and values are strings. The variables can also be read
+// and assigned as attributes, process.env.HOME, except the ones named like
+// its methods.
+type Env struct {
+	VTp     string `json:"value type"`
+	process Process
+}
+
+func Create_Env(process Process) Value {
+	re := Env{process: process}
+	re.VTp = re.VType()
+	return re
+}
+func (this Env) On_sum(value Value) Value {
+	return Create_Null()
+}
+func (this Env) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this Env) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this Env) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this Env) Re_string(prefix string) string {
+	return "<env>"
+}
+func (this Env) Re_number() float64 {
+	return -1
+}
+func (this Env) Re_bool() bool {
+	return true
+}
+func (this Env) VType() string {
+	return "Env"
+}
+func (this Env) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	return Create_Null(), nil
+}
+func (this Env) On_get_attr(name string) Value {
+	switch name {
+	case "get":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			key, ok := get_arg(args, kwargs, 0, "name")
+			if !ok {
+				return Create_Null(), nil
+			}
+			if v, ok := this.process.Getenv(key.Re_string("")); ok {
+				return Create_String(v), nil
+			}
+			return Create_Null(), nil
+		})
+	case "set":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			key, ok := get_arg(args, kwargs, 0, "name")
+			if !ok {
+				return Create_Null(), nil
+			}
+			value, ok := get_arg(args, kwargs, 1, "value")
+			if !ok {
+				value = Create_String("")
+			}
+			if err := this.process.Setenv(key.Re_string(""), value.Re_string("")); err != nil {
+				return Create_Null(), err
+			}
+			return value, nil
+		})
+	case "has":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			key, ok := get_arg(args, kwargs, 0, "name")
+			if !ok {
+				return Create_Bool(false), nil
+			}
+			_, ok = this.process.Getenv(key.Re_string(""))
+			return Create_Bool(ok), nil
+		})
+	case "delete":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			key, ok := get_arg(args, kwargs, 0, "name")
+			if !ok {
+				return Create_Bool(false), nil
+			}
+			_, ok = this.process.Getenv(key.Re_string(""))
+			if err := this.process.Unsetenv(key.Re_string("")); err != nil {
+				return Create_Null(), err
+			}
+			return Create_Bool(ok), nil
+		})
+	case "keys":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			re := []Value{}
+			for _, k := range this.process.Env_Names() {
+				re = append(re, Create_String(k))
+			}
+			return Create_Array(re), nil
+		})
+	}
+	if v, ok := this.process.Getenv(name); ok {
+		return Create_String(v)
+	}
+	return Create_Null()
+}
+func (this Env) Attr_Names() []string {
+	return append([]string{"get", "set", "has", "delete", "keys"}, this.process.Env_Names()...)
+}
+func (this Env) On_set_attr(name string, value Value) Value {
+	if err := this.process.Setenv(name, value.Re_string("")); err != nil {
+		return Create_Null()
+	}
+	return value
+}
+func (this Env) On_in(name Value) Value {
+	_, ok := this.process.Getenv(name.Re_string(""))
+	return Create_Bool(ok)
+}
+func (this Env) Re_hash() (string, bool) {
+	return "", false
+}
+
+// create_process is the process global, frozen so only its env changes.
+func create_process(process Process) Value {
+	args := []Value{}
+	for _, a := range process.Args() {
+		args = append(args, Create_String(a))
+	}
+	re := Create_Object(map[string]Value{
+		"args": Create_Array(args),
+		"env":  Create_Env(process),
+		"cwd": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			dir, err := process.Cwd()
+			if err != nil {
+				return Create_Null(), err
+			}
+			return Create_String(dir), nil
+		}),
+		"exit": Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			code := 0
+			if v, ok := get_arg(args, kwargs, 0, "code"); ok {
+				n := v.Re_number()
+				if v.VType() != "Number" || n != math.Trunc(n) || n < 0 || n > 255 {
+					return Create_Null(), Error{code: Code_Invalid_Exit_Code, kind: "erro3", key: "erro msg30", args: []string{v.Re_string("")}}
+				}
+				code = int(n)
+			}
+			return Create_Null(), Exit{Code: code}
+		}),
+		"pid":      Create_Number(float64(process.Pid())),
+		"platform": Create_String(process.Platform()),
+	}).(Object)
+	re.Freeze()
+	return re
+}
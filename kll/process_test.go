@@ -0,0 +1,85 @@
+package kll
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVirtualProcess(t *testing.T) {
+	for _, vm := range []bool{false, true} {
+		process := &Virtual_Process{Arguments: []string{"a", "b"}, Env: map[string]string{"HOME": "/home/kll", "OLD": "1"}, Dir: "/work", Id: 42, OS: "kllos"}
+		inter := Interpreter{Use_VM: vm, Process: process}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		v, err := inter.Eval(`process.env.LANG = "pt"
+process.env.set("COUNT", 3)
+var deleted = process.env.delete("OLD")
+[process.args, process.env.HOME, process.env.get("LANG"), process.env.get("NOPE"), "HOME" in process.env, process.env.has("OLD"), deleted, process.env.keys(), process.cwd(), process.pid, process.platform]`, &locals)
+		if err != nil {
+			t.Fatalf("vm=%v: %v", vm, err)
+		}
+		want := `[["a", "b"], "/home/kll", "pt", null, true, false, true, ["COUNT", "HOME", "LANG"], "/work", 42, "kllos"]`
+		if got := v.Re_string(""); got != want {
+			t.Errorf("vm=%v: got  %s\nwant %s", vm, got, want)
+		}
+		if process.Env["LANG"] != "pt" || process.Env["COUNT"] != "3" {
+			t.Errorf("vm=%v: the changes didn't reach the process: %v", vm, process.Env)
+		}
+		// only env changes, the rest of process is frozen
+		if v, err := inter.Eval("process.pid = 7\nprocess.pid", &locals); err != nil || v.Re_string("") != "42" {
+			t.Errorf("vm=%v: process.pid became %v, %v", vm, v, err)
+		}
+		if _, err := inter.Eval(`process.env.HOEM`, &locals); !errors.Is(err, Code_Missing_Attribute) {
+			t.Errorf("vm=%v: reading a missing variable gave %v, want %s", vm, err, Code_Missing_Attribute)
+		}
+	}
+}
+
+func TestProcessExit(t *testing.T) {
+	tests := []struct {
+		source string
+		code   int
+		err    Code
+	}{
+		{source: `process.exit()`, code: 0},
+		{source: `process.exit(3)`, code: 3},
+		{source: `process.exit(code = 255)`, code: 255},
+		{source: `process.exit("abc")`, err: Code_Invalid_Exit_Code},
+		{source: `process.exit("1")`, err: Code_Invalid_Exit_Code},
+		{source: `process.exit(300)`, err: Code_Invalid_Exit_Code},
+		{source: `process.exit(-1)`, err: Code_Invalid_Exit_Code},
+		{source: `process.exit(1.5)`, err: Code_Invalid_Exit_Code},
+	}
+	for _, test := range tests {
+		for _, vm := range []bool{false, true} {
+			inter := Interpreter{Use_VM: vm, Process: &Virtual_Process{}}
+			inter.Init()
+			locals := Create_Object(nil).(Object)
+			_, err := inter.Eval(test.source, &locals)
+			if test.err != "" {
+				if !errors.Is(err, test.err) {
+					t.Errorf("vm=%v: %s gave %v, want %s", vm, test.source, err, test.err)
+				}
+				continue
+			}
+			var exit Exit
+			if !errors.As(err, &exit) || exit.Code != test.code {
+				t.Errorf("vm=%v: %s gave %v, want exit %d", vm, test.source, err, test.code)
+			}
+		}
+	}
+}
+
+func TestNoProcess(t *testing.T) {
+	for _, vm := range []bool{false, true} {
+		inter := Interpreter{Use_VM: vm, No_Process: true}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		if v, err := inter.Eval(`exist process`, &locals); err != nil || v.Re_bool() {
+			t.Errorf("vm=%v: exist process gave %v, %v, want false", vm, v, err)
+		}
+		if _, err := inter.Eval(`process.env.HOME`, &locals); !errors.Is(err, Code_Undefined_Variable) {
+			t.Errorf("vm=%v: got %v, want %s", vm, err, Code_Undefined_Variable)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package kll
+
+import "math"
+
+// Range is the lazy sequence produced by 0..10, 0..<10 and range(). It only
+// keeps its first value, step and length, so iterating, testing membership
+// or reversing never allocates an Array. stop and inclusive are the bounds
+// it was written with, for printing it.
+type Range struct {
+	VTp       string `json:"value type"`
+	start     float64
+	step      float64
+	count     int
+	stop      float64
+	inclusive bool
+}
+
+func (this Range) On_sum(value Value) Value {
+	return Create_Null()
+}
+func (this Range) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this Range) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this Range) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this Range) Re_string(prefix string) string {
+	start, stop := Create_Number(this.start).Re_string(""), Create_Number(this.stop).Re_string("")
+	if this.inclusive && this.step == 1 {
+		return start + ".." + stop
+	}
+	return "range(" + start + ", " + stop + ", " + Create_Number(this.step).Re_string("") + ")"
+}
+func (this Range) Re_number() float64 {
+	return float64(this.count)
+}
+func (this Range) Re_bool() bool {
+	return this.count > 0
+}
+func (this Range) VType() string {
+	return "Range"
+}
+func (this Range) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	return Create_Null(), nil
+}
+func (this Range) On_get_attr(name string) Value {
+	switch name {
+	case "length":
+		return Create_Number(float64(this.count))
+	case "start":
+		return Create_Number(this.start)
+	case "stop":
+		return Create_Number(this.stop)
+	case "step":
+		return Create_Number(this.step)
+	case "reverse":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			if this.count == 0 {
+				return this, nil
+			}
+			return Create_Range(this.at(this.count-1), -this.step, this.count), nil
+		})
+	case "includes":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			value, ok := get_arg(args, kwargs, 0, "value")
+			if !ok {
+				return Create_Bool(false), nil
+			}
+			return this.On_in(value), nil
+		})
+	case "to_array":
+		return Create_GoFunction(func(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+			values := make([]Value, this.count)
+			for i := range values {
+				values[i] = Create_Number(this.at(i))
+			}
+			return Create_Array(values), nil
+		})
+	}
+	return Create_Null()
+}
+func (this Range) Attr_Names() []string {
+	return []string{"length", "start", "stop", "step", "reverse", "includes", "to_array"}
+}
+func (this Range) On_set_attr(name string, value Value) Value {
+	return Create_Null()
+}
+func (this Range) On_in(name Value) Value {
+	if name.VType() != "Number" || this.count == 0 {
+		return Create_Bool(false)
+	}
+	k := (name.Re_number() - this.start) / this.step
+	i := math.Round(k)
+	return Create_Bool(math.Abs(k-i) < 1e-9 && i >= 0 && i < float64(this.count))
+}
+func (this Range) Re_hash() (string, bool) {
+	return "r:" + Create_Number(this.start).Re_string("") + ":" + Create_Number(this.step).Re_string("") + ":" + Create_Number(float64(this.count)).Re_string(""), true
+}
+func (this Range) On_iter() (Iterator, any) {
+	i := 0
+	return Create_GoIterator(func() (Value, bool, any) {
+		if i >= this.count {
+			return nil, false, nil
+		}
+		i++
+		return Create_Number(this.at(i - 1)), true, nil
+	}, nil).(GoIterator), nil
+}
+func (this Range) at(i int) float64 {
+	return this.start + float64(i)*this.step
+}
+
+// Create_Range builds the range of count values from start moving by step;
+// its stop is the value after the last.
+func Create_Range(start float64, step float64, count int) Value {
+	if count < 0 {
+		count = 0
+	}
+	re := Range{start: start, step: step, count: count, stop: start + float64(count)*step}
+	re.VTp = re.VType()
+	return re
+}
+
+// Range_Between builds the range from start to stop moving by step, with
+// stop itself included when inclusive is true.
+func Range_Between(start float64, stop float64, step float64, inclusive bool) (Value, any) {
+	if step == 0 || math.IsNaN(step) || math.IsInf(step, 0) {
+		return Create_Null(), Error{code: Code_Zero_Step, kind: "erro3", key: "erro msg15"}
+	}
+	n := (stop - start) / step
+	count := int(math.Ceil(n - 1e-9))
+	if inclusive {
+		count = int(math.Floor(n+1e-9)) + 1
+	}
+	re := Create_Range(start, step, count).(Range)
+	re.stop, re.inclusive = stop, inclusive
+	return re, nil
+}
+func New_Range(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	start, stop, step := 0.0, 0.0, 1.0
+	if len(args) == 1 {
+		stop = args[0].Re_number()
+	} else {
+		if v, ok := get_arg(args, kwargs, 0, "start"); ok {
+			start = v.Re_number()
+		}
+		if v, ok := get_arg(args, kwargs, 1, "stop"); ok {
+			stop = v.Re_number()
+		}
+	}
+	if v, ok := get_arg(args, kwargs, 2, "step"); ok {
+		step = v.Re_number()
+	}
+	return Range_Between(start, stop, step, false)
+}
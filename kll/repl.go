@@ -0,0 +1,205 @@
+package kll
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Keywords are the words the lexer doesn't read as variable names.
+var Keywords = []string{"and", "exist", "for", "function", "global", "if", "in", "local", "or", "return", "var", "yield"}
+
+type repl struct {
+	inter  *Interpreter
+	locals *Object
+	out    io.Writer
+	editor *line_editor
+}
+
+// Repl runs the lines read from in one after the other, sharing the
+// variables, and writes the value of each to out. A line with unclosed
+// braces, parentheses or brackets continues on the next. The lines are
+// kept in the history file, unless it is "". Repl returns when in ends,
+// or the Exit of process.exit.
+func (this *Interpreter) Repl(in *os.File, out io.Writer, history string) error {
+	this.Init()
+	locals := Create_Object(make(map[string]Value)).(Object)
+	locals.Create_Var("__name__", 0, Create_String("__main__"), true)
+	this.module = "<repl>"
+	r := &repl{inter: this, locals: &locals, out: out, editor: create_line_editor(in, out, history)}
+	r.editor.complete = r.complete
+	fmt.Fprintln(out, lang_text_in(this.Locale, "repl banner", nil))
+	src := ""
+	for {
+		prompt := "> "
+		if src != "" {
+			prompt = "... "
+		}
+		line, err := r.editor.read(prompt)
+		if err == err_interrupt {
+			src = ""
+			continue
+		}
+		if err != nil {
+			return nil
+		}
+		r.editor.add(line)
+		if src == "" && is_repl_command(line) {
+			if exit, err := r.command(strings.TrimSpace(line)); exit {
+				return err
+			}
+			continue
+		}
+		src += line + "\n"
+		if open_brackets(src) > 0 {
+			continue
+		}
+		if err := r.eval(src, true); err != nil {
+			return err
+		}
+		src = ""
+	}
+}
+
+// open_brackets counts the braces, parentheses and brackets of txt that
+// weren't closed.
+func open_brackets(txt string) int {
+	var l Lexer
+	tokens, _ := l.Tokenizer(txt)
+	re := 0
+	for _, t := range tokens {
+		switch t.tp {
+		case "{", "(", "[":
+			re++
+		case "}", ")", "]":
+			re--
+		}
+	}
+	return re
+}
+func is_repl_command(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) > 1 && line[0] == '.' && strings.Contains(varsName, line[1:2])
+}
+
+// eval runs src, printing its value when show is set. Errors, even a
+// panic of a GoFunction, are printed and leave the session running; only
+// the Exit of process.exit is returned.
+func (this *repl) eval(src string, show bool) error {
+	defer func() {
+		if e := recover(); e != nil {
+			fmt.Fprintln(this.out, e)
+			this.inter.calls = nil
+			this.inter.entering = false
+		}
+	}()
+	this.inter.parser.Locale = this.inter.Locale
+	nodes, err := this.inter.parser.Parse(src)
+	if !livre(err, nil) {
+		return nil
+	}
+	re, err := this.inter.run(nodes, this.locals)
+	var exit Exit
+	if errors.As(to_error(err), &exit) {
+		return exit
+	}
+	if err != nil {
+		livre(this.inter.with_lines(err, src), nil)
+		return nil
+	}
+	if show && re != nil && re.VType() != "Null" && !is_declaration(nodes[len(nodes)-1]) {
+		fmt.Fprintln(this.out, re.Re_string(""))
+	}
+	return nil
+}
+
+// is_declaration tells the statements whose value the repl doesn't show.
+func is_declaration(v Value) bool {
+	node, ok := v.(Node)
+	if !ok {
+		return false
+	}
+	switch node.Tp {
+	case "create local", "create global":
+		return true
+	case "function", "generator":
+		return node.Value[0].Re_string("") != ""
+	}
+	return false
+}
+
+// command runs a line starting with a dot, and returns true for .exit
+// and when the file of .load called process.exit.
+func (this *repl) command(line string) (bool, error) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case ".exit":
+		return true, nil
+	case ".help":
+		fmt.Fprintln(this.out, lang_text_in(this.inter.Locale, "repl help", nil))
+	case ".load":
+		txt, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintln(this.out, err)
+			break
+		}
+		module := this.inter.module
+		this.inter.module = arg
+		err = this.eval(string(txt), false)
+		this.inter.module = module
+		if err != nil {
+			return true, err
+		}
+	case ".ast":
+		this.inter.parser.Locale = this.inter.Locale
+		nodes, err := this.inter.parser.Parse(arg)
+		if !livre(err, nil) {
+			break
+		}
+		for _, n := range nodes {
+			fmt.Fprintln(this.out, n.Re_string("  "))
+		}
+	default:
+		fmt.Fprintln(this.out, lang_text_in(this.inter.Locale, "repl unknown command", []string{name}))
+	}
+	return false, nil
+}
+
+// complete lists the variables and keywords starting with word or, when
+// word is a path like console.lo, the attributes of the value it names.
+func (this *repl) complete(word string) []string {
+	path := strings.Split(word, ".")
+	prefix := path[len(path)-1]
+	names := []string{}
+	if len(path) == 1 {
+		names = append(this.inter.visible_names(this.locals), Keywords...)
+	} else {
+		scope := this.inter.find_scope(this.locals, path[0])
+		if scope == nil {
+			return nil
+		}
+		v := scope.On_get_attr(path[0])
+		for _, name := range path[1 : len(path)-1] {
+			v = v.On_get_attr(name)
+		}
+		names, _ = Attr_Names(v)
+	}
+	base := strings.Join(path[:len(path)-1], ".")
+	if base != "" {
+		base += "."
+	}
+	re := []string{}
+	seen := map[string]bool{}
+	for _, n := range names {
+		if strings.HasPrefix(n, prefix) && !seen[n] {
+			seen[n] = true
+			re = append(re, base+n)
+		}
+	}
+	sort.Strings(re)
+	return re
+}
@@ -0,0 +1,563 @@
+package kll
+
+import "sort"
+
+// frame holds the variables declared inside a function call, or inside a
+// module, in the slots Resolve assigned to them. parent is the frame the
+// function was declared in, and gen the generator whose body the call runs,
+// which the yields of the call, and only them, hand values to. module is
+// only set on the frame of a module.
+type frame struct {
+	slots  []Value
+	parent *frame
+	gen    *generator_state
+	module *module_vars
+	// small holds the slots of a frame that has few, so creating it takes
+	// one allocation.
+	small [4]Value
+}
+
+// module_vars are the variables declared at the top of the scripts run with
+// a locals Object. The frame holding them is kept in the meta of locals, so
+// the scripts run with it later, like the lines of the repl, reach them in
+// the same slots, and every store to them is mirrored in locals, where
+// embedders and importers read them by name. Their slots are nil until the
+// declaration runs.
+type module_vars struct {
+	locals *Object
+	slots  map[string]int
+	// names is the name of each slot, "" for the slots of blocks, and
+	// entries where it was last seen in the OrderedMap of locals.
+	names   []string
+	entries []int
+}
+
+func new_frame(slots int, parent *frame) *frame {
+	re := &frame{parent: parent}
+	if slots <= len(re.small) {
+		re.slots = re.small[:slots]
+	} else {
+		re.slots = make([]Value, slots)
+	}
+	for i := range re.slots {
+		re.slots[i] = null_value
+	}
+	return re
+}
+
+// module_frame returns the frame of the variables of the module run with
+// locals, creating it the first time.
+func module_frame(locals *Object) *frame {
+	if locals.meta.module == nil {
+		locals.meta.module = &frame{module: &module_vars{locals: locals, slots: map[string]int{}}}
+	}
+	return locals.meta.module
+}
+
+// grow makes room for the slots Resolve gave to a module frame. A new slot
+// of a variable takes the value locals already has for it.
+func (this *frame) grow(slots int) {
+	names := make([]string, slots)
+	for name, slot := range this.module.slots {
+		names[slot] = name
+	}
+	for i := len(this.slots); i < slots; i++ {
+		var v Value = null_value
+		if names[i] != "" {
+			v = nil
+			if e, ok := this.module.locals.value.Get(names[i]); ok {
+				v = e.Value
+			}
+		}
+		this.slots = append(this.slots, v)
+		this.module.entries = append(this.module.entries, -1)
+	}
+	this.module.names = names
+}
+
+// iteration returns the frame of one iteration of a loop that has its own,
+// whose yields still go to the generator of the loop.
+func (this *frame) iteration(slots int) *frame {
+	re := new_frame(slots, this)
+	re.gen = this.gen
+	return re
+}
+func (this *frame) at(depth int) *frame {
+	f := this
+	for ; depth > 0; depth-- {
+		f = f.parent
+	}
+	return f
+}
+
+// set stores value in a slot of the frame, and in locals when the slot is
+// a variable of the module.
+func (this *frame) set(slot int, value Value) {
+	this.slots[slot] = value
+	if this.module == nil || this.module.names[slot] == "" {
+		return
+	}
+	m := this.module
+	if !m.locals.value.set_at(m.entries[slot], m.names[slot], value) {
+		m.locals.value.Set_Value(m.names[slot], value)
+		m.entries[slot] = m.locals.value.index[m.names[slot]]
+	}
+}
+
+// sync is the other side of set: it gives the slot of a variable of the
+// module the value set in locals by name, or nil when it was deleted.
+func (this *frame) sync(name string, value Value) {
+	if slot, ok := this.module.slots[name]; ok && slot < len(this.slots) {
+		this.slots[slot] = value
+	}
+}
+
+// load_slot reads a slot of the frame depth levels above env. A variable
+// of the module is looked up by name until its declaration runs, like it
+// was before having a slot.
+func (this *Interpreter) load_slot(env *frame, depth int, slot int, locals *Object, line uint64, col uint64) (Value, any) {
+	f := env.at(depth)
+	if v := f.slots[slot]; v != nil {
+		return v, nil
+	}
+	name := f.module.names[slot]
+	if scope := this.find_scope(locals, name); scope != nil {
+		return scope.On_get_attr(name), nil
+	}
+	return nil, var_error(name, line, col, this.visible_names(locals))
+}
+
+// store_slot assigns a slot of the frame depth levels above env, by name
+// while it is nil like load_slot.
+func (this *Interpreter) store_slot(env *frame, depth int, slot int, value Value, locals *Object, line uint64, col uint64) any {
+	f := env.at(depth)
+	if f.slots[slot] != nil {
+		f.set(slot, value)
+		return nil
+	}
+	name := f.module.names[slot]
+	if scope := this.find_scope(locals, name); scope != nil {
+		if !scope.is_const(name) {
+			scope.On_set_attr(name, value)
+		}
+		return nil
+	}
+	return var_error(name, line, col, this.visible_names(locals))
+}
+
+type resolver struct {
+	frames  []*resolver_frame
+	module  map[string]bool
+	defined func(name string) bool
+	known   func() []string
+	// guarded are the names tested with exist somewhere in the file, which
+	// may be missing on purpose.
+	guarded map[string]bool
+	// vars gives slots to the names declared at the top of the module, which
+	// stay in locals when it is nil.
+	vars   *module_vars
+	errors []any
+}
+type resolver_frame struct {
+	scopes []map[string]int
+	slots  int
+}
+
+// Resolve runs after Parser.Parse. Every variable declared inside a
+// function or a block gets a slot in the frame of the function, or of the
+// module, that declares it, and the "var" nodes naming it are rewritten to
+// [name, depth, slot] so both engines reach it without looking the name
+// up. Function nodes get the size of their frame and the slot of their
+// name appended, and so do the for loops whose body creates functions the
+// size of the frame each iteration gets. Names declared at the top of the module or with global
+// stay in locals and Globals; Interpreter.run gives slots to the first
+// ones too, mirrored in locals.
+//
+// Resolve returns the number of slots of the module frame. A name that is
+// neither declared by the script nor reported by defined is returned as an
+// error, unless the script tests it with exist; a nil defined skips that
+// check.
+func Resolve(nodes []Value, defined func(name string) bool) (int, []any) {
+	return resolve(nodes, defined, nil, nil)
+}
+
+// resolve is Resolve, with known listing the names defined reports so
+// the errors can suggest them. When vars is not nil the names declared at
+// the top of the module get slots too, the ones vars already has keeping
+// theirs.
+func resolve(nodes []Value, defined func(name string) bool, known func() []string, vars *module_vars) (int, []any) {
+	r := &resolver{frames: []*resolver_frame{{}}, module: map[string]bool{}, defined: defined, known: known, guarded: map[string]bool{}, vars: vars}
+	if vars != nil {
+		r.frames[0].slots = len(vars.names)
+	}
+	collect_globals(nodes, r.module)
+	collect_guarded(nodes, r.guarded)
+	r.block(nodes)
+	return r.frames[0].slots, r.errors
+}
+
+// collect_globals adds the names created with global anywhere in nodes,
+// since they can be used before the function declaring them runs.
+func collect_globals(nodes []Value, names map[string]bool) {
+	for _, v := range nodes {
+		node, ok := v.(Node)
+		if !ok {
+			continue
+		}
+		if node.Tp == "create global" {
+			if t := node.Value[0].(Node); t.Tp == "var" {
+				names[t.Value[0].Re_string("")] = true
+			} else if t.Tp == "=" {
+				for _, name := range target_names(t.Value[0].(Node)) {
+					names[name] = true
+				}
+			}
+		}
+		collect_globals(node.Value, names)
+	}
+}
+
+// collect_guarded adds the names tested with exist anywhere in nodes.
+func collect_guarded(nodes []Value, names map[string]bool) {
+	for _, v := range nodes {
+		node, ok := v.(Node)
+		if !ok {
+			continue
+		}
+		if node.Tp == "exist" {
+			names[node.Value[0].(Node).Value[0].Re_string("")] = true
+		}
+		collect_guarded(node.Value, names)
+	}
+}
+func target_names(target Node) []string {
+	switch target.Tp {
+	case "var":
+		return []string{target.Value[0].Re_string("")}
+	case "array":
+		re := []string{}
+		for _, t := range target.Value {
+			if t.(Node).Tp == "spread" {
+				t = t.(Node).Value[0]
+			}
+			re = append(re, target_names(t.(Node))...)
+		}
+		return re
+	}
+	return nil
+}
+
+func (this *resolver) frame() *resolver_frame {
+	return this.frames[len(this.frames)-1]
+}
+func (this *resolver) begin_scope() {
+	f := this.frame()
+	f.scopes = append(f.scopes, map[string]int{})
+}
+func (this *resolver) end_scope() {
+	f := this.frame()
+	f.scopes = f.scopes[:len(f.scopes)-1]
+}
+
+// top_level is true outside of any function and block, where variables
+// are kept by name.
+func (this *resolver) top_level() bool {
+	return len(this.frames) == 1 && len(this.frames[0].scopes) == 0
+}
+func (this *resolver) declare(name string) int {
+	f := this.frame()
+	scope := f.scopes[len(f.scopes)-1]
+	if slot, ok := scope[name]; ok {
+		return slot
+	}
+	scope[name] = f.slots
+	f.slots++
+	return f.slots - 1
+}
+
+// declare_module gives a slot to a name declared at the top of the module,
+// or returns -1 when those stay in locals.
+func (this *resolver) declare_module(name string) int {
+	this.module[name] = true
+	if this.vars == nil {
+		return -1
+	}
+	if slot, ok := this.vars.slots[name]; ok {
+		return slot
+	}
+	this.vars.slots[name] = this.frames[0].slots
+	this.frames[0].slots++
+	return this.frames[0].slots - 1
+}
+
+// lookup finds the slot of name. The ones of the variables of the module
+// are left out for exist, which looks them up by name as they may not be
+// declared yet.
+func (this *resolver) lookup(name string, module bool) (int, int, bool) {
+	for d := len(this.frames) - 1; d >= 0; d-- {
+		f := this.frames[d]
+		for i := len(f.scopes) - 1; i >= 0; i-- {
+			if slot, ok := f.scopes[i][name]; ok {
+				return len(this.frames) - 1 - d, slot, true
+			}
+		}
+	}
+	if module && this.vars != nil {
+		if slot, ok := this.vars.slots[name]; ok {
+			return len(this.frames) - 1, slot, true
+		}
+	}
+	return 0, 0, false
+}
+
+// hoist declares the variables and named functions of a block before it
+// runs, so functions can refer to the ones declared after them.
+func (this *resolver) hoist(nodes []Value) {
+	for _, v := range nodes {
+		node := v.(Node)
+		names := []string{}
+		switch node.Tp {
+		case "create local":
+			if t := node.Value[0].(Node); t.Tp == "var" {
+				names = target_names(t)
+			} else if t.Tp == "=" {
+				names = target_names(t.Value[0].(Node))
+			}
+		case "function", "generator":
+			if name := node.Value[0].Re_string(""); name != "" {
+				names = []string{name}
+			}
+		}
+		for _, name := range names {
+			if this.top_level() {
+				this.declare_module(name)
+			} else {
+				this.declare(name)
+			}
+		}
+	}
+}
+func (this *resolver) block(nodes []Value) {
+	this.hoist(nodes)
+	for i := range nodes {
+		nodes[i] = this.node(nodes[i])
+	}
+}
+
+// ref annotates a "var" node that reads or assigns a variable.
+func (this *resolver) ref(node Node, report bool) Value {
+	name := node.Value[0].Re_string("")
+	if depth, slot, ok := this.lookup(name, report); ok {
+		return Create_Node([]Value{node.Value[0], Create_Number(float64(depth)), Create_Number(float64(slot))}, "var", node.Line, node.Col)
+	}
+	if report && this.defined != nil && !this.module[name] && !this.guarded[name] && !this.defined(name) {
+		this.errors = append(this.errors, var_error(name, node.Line, node.Col, this.names()))
+	}
+	if locals := this.local_names(); len(locals) > 0 {
+		// the variables in slots can't be listed when running, so they
+		// are kept for the suggestion of the error if name is missing
+		return Create_Node([]Value{node.Value[0], Create_Tuple(locals)}, "var", node.Line, node.Col)
+	}
+	return Create_Node(node.Value[:1], "var", node.Line, node.Col)
+}
+
+// local_names lists the variables in slots visible where the resolver is.
+func (this *resolver) local_names() []Value {
+	re := []Value{}
+	for _, f := range this.frames {
+		for _, scope := range f.scopes {
+			for name := range scope {
+				re = append(re, Create_String(name))
+			}
+		}
+	}
+	sort.Slice(re, func(i, j int) bool {
+		return re[i].Re_string("") < re[j].Re_string("")
+	})
+	return re
+}
+
+// var_hints returns the names ref kept in an unresolved "var" node.
+func var_hints(node Node) []string {
+	re := []string{}
+	if len(node.Value) == 2 {
+		for _, v := range node.Value[1].(Tuple).Value {
+			re = append(re, v.Re_string(""))
+		}
+	}
+	return re
+}
+
+// names lists the variables visible where the resolver is.
+func (this *resolver) names() []string {
+	re := []string{}
+	for _, f := range this.frames {
+		for _, scope := range f.scopes {
+			for name := range scope {
+				re = append(re, name)
+			}
+		}
+	}
+	for name := range this.module {
+		re = append(re, name)
+	}
+	if this.known != nil {
+		re = append(re, this.known()...)
+	}
+	return re
+}
+
+// targets annotates the variables created by a declaration or a for loop.
+func (this *resolver) targets(target Value) Value {
+	node := target.(Node)
+	switch node.Tp {
+	case "var":
+		if this.top_level() {
+			if slot := this.declare_module(node.Value[0].Re_string("")); slot >= 0 {
+				return Create_Node([]Value{node.Value[0], Create_Number(0), Create_Number(float64(slot))}, "var", node.Line, node.Col)
+			}
+			return Create_Node(node.Value[:1], "var", node.Line, node.Col)
+		}
+		slot := this.declare(node.Value[0].Re_string(""))
+		return Create_Node([]Value{node.Value[0], Create_Number(0), Create_Number(float64(slot))}, "var", node.Line, node.Col)
+	case "array":
+		for i, t := range node.Value {
+			if t.(Node).Tp == "spread" {
+				t.(Node).Value[0] = this.targets(t.(Node).Value[0])
+			} else {
+				node.Value[i] = this.targets(t)
+			}
+		}
+	}
+	return node
+}
+func (this *resolver) node(v Value) Value {
+	node := v.(Node)
+	switch node.Tp {
+	case "var":
+		return this.ref(node, true)
+	case "exist":
+		node.Value[0] = this.ref(node.Value[0].(Node), false)
+	case "get attr":
+		node.Value[0] = this.node(node.Value[0])
+	case "call":
+		node.Value[0] = this.node(node.Value[0])
+		params := node.Value[1].(Node).Value
+		for i, p := range params {
+			if p.(Node).Tp == "=" {
+				p.(Node).Value[1] = this.node(p.(Node).Value[1])
+			} else {
+				params[i] = this.node(p)
+			}
+		}
+	case "=":
+		node.Value[1] = this.node(node.Value[1])
+		target := node.Value[0].(Node)
+		if target.Tp == "get attr" {
+			target.Value[0] = this.node(target.Value[0])
+		} else if target.Tp == "var" {
+			node.Value[0] = this.ref(target, true)
+		}
+	case "create local":
+		target := node.Value[0].(Node)
+		if target.Tp == "var" {
+			node.Value[0] = this.targets(target)
+		} else if target.Tp == "=" {
+			target.Value[1] = this.node(target.Value[1])
+			target.Value[0] = this.targets(target.Value[0])
+		}
+	case "create global":
+		if target := node.Value[0].(Node); target.Tp == "=" {
+			target.Value[1] = this.node(target.Value[1])
+		}
+	case "function", "generator":
+		this.frames = append(this.frames, &resolver_frame{scopes: []map[string]int{{}}})
+		params := node.Value[1].(Node).Value
+		for i, p := range params {
+			if p.(Node).Tp == "var" {
+				params[i] = this.targets(p)
+			}
+		}
+		this.block(node.Value[2].(Node).Value)
+		slots := this.frame().slots
+		this.frames = this.frames[:len(this.frames)-1]
+		name := -1
+		if n := node.Value[0].Re_string(""); n != "" && this.top_level() {
+			name = this.declare_module(n)
+		} else if n != "" {
+			name = this.declare(n)
+		}
+		return Create_Node([]Value{node.Value[0], node.Value[1], node.Value[2], Create_Number(float64(slots)), Create_Number(float64(name))}, node.Tp, node.Line, node.Col)
+	case "if":
+		node.Value[0] = this.node(node.Value[0])
+		this.begin_scope()
+		this.block(node.Value[1].(Node).Value)
+		this.end_scope()
+	case "for":
+		node.Value[1] = this.node(node.Value[1])
+		if !has_function(node.Value[2].(Node).Value) {
+			this.begin_scope()
+			node.Value[0] = this.targets(node.Value[0])
+			this.block(node.Value[2].(Node).Value)
+			this.end_scope()
+			break
+		}
+		// the functions created by the body keep the variables of the
+		// iteration they were created in, so each one gets its own frame
+		this.frames = append(this.frames, &resolver_frame{scopes: []map[string]int{{}}})
+		node.Value[0] = this.targets(node.Value[0])
+		this.block(node.Value[2].(Node).Value)
+		slots := this.frame().slots
+		this.frames = this.frames[:len(this.frames)-1]
+		return Create_Node([]Value{node.Value[0], node.Value[1], node.Value[2], Create_Number(float64(slots))}, node.Tp, node.Line, node.Col)
+	case "array":
+		for i := range node.Value {
+			node.Value[i] = this.node(node.Value[i])
+		}
+	case "spread", "()", "inverse number", "return", "yield":
+		node.Value[0] = this.node(node.Value[0])
+	case "+", "-", "*", "/", "==", "&&", "||", "in", "range":
+		node.Value[0] = this.node(node.Value[0])
+		node.Value[1] = this.node(node.Value[1])
+	}
+	return node
+}
+
+// function_slots reads the annotations Resolve appends to function nodes.
+func function_slots(node Node) (int, int) {
+	if len(node.Value) < 5 {
+		return 0, -1
+	}
+	return int(node.Value[3].Re_number()), int(node.Value[4].Re_number())
+}
+
+// loop_slots returns the size of the frame Resolve gave to each iteration
+// of a for loop, false when the iterations share the frame around them.
+func loop_slots(node Node) (int, bool) {
+	if len(node.Value) < 4 {
+		return 0, false
+	}
+	return int(node.Value[3].Re_number()), true
+}
+
+// has_function is true when nodes create a function anywhere.
+func has_function(nodes []Value) bool {
+	for _, v := range nodes {
+		node, ok := v.(Node)
+		if !ok {
+			continue
+		}
+		if node.Tp == "function" || node.Tp == "generator" || has_function(node.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// var_slot returns the depth and slot of a resolved "var" node.
+func var_slot(node Node) (int, int, bool) {
+	if len(node.Value) < 3 {
+		return 0, 0, false
+	}
+	return int(node.Value[1].Re_number()), int(node.Value[2].Re_number()), true
+}
@@ -0,0 +1,132 @@
+package kll
+
+import (
+	"sort"
+	"strings"
+)
+
+// Attr_Lister is implemented by the values that know every attribute
+// On_get_attr gives them: the methods of the built-in types and the keys
+// of Objects. Values that don't implement it are never reported as
+// missing an attribute.
+type Attr_Lister interface {
+	Attr_Names() []string
+}
+
+// Attr_Names lists the attributes of value, and false when its type can't
+// enumerate them. Pointers list the attributes of the value they point to.
+func Attr_Names(value Value) ([]string, bool) {
+	if p, ok := value.(Pointer); ok {
+		return Attr_Names(p.value.Value)
+	}
+	l, ok := value.(Attr_Lister)
+	if !ok {
+		return nil, false
+	}
+	return l.Attr_Names(), true
+}
+
+// edit_distance is the Levenshtein distance between a and b, with the swap
+// of two letters next to each other counted as one edit, as in lenght.
+func edit_distance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// two rows back are needed for the swaps
+	before := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min_int(prev[j]+1, min_int(cur[j-1]+1, prev[j-1]+cost))
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min_int(cur[j], before[j-2]+1)
+			}
+		}
+		before, prev, cur = prev, cur, before
+	}
+	return prev[len(rb)]
+}
+func min_int(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// closest_name is the name of names nearest to name, or "" when none is
+// close enough to be a typo of it.
+func closest_name(name string, names []string) string {
+	limit := len([]rune(name)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+	re, best := "", limit+1
+	for _, n := range names {
+		if n == name {
+			continue
+		}
+		d := edit_distance(strings.ToLower(name), strings.ToLower(n))
+		if d < best || d == best && n < re {
+			re, best = n, d
+		}
+	}
+	return re
+}
+
+// visible_names lists the variables that can be read from locals.
+func (this *Interpreter) visible_names(locals *Object) []string {
+	re := []string{}
+	for scope := locals; scope != nil; scope = scope.meta.parent {
+		re = append(re, scope.Keys()...)
+	}
+	return append(re, this.Globals.Keys()...)
+}
+
+func var_error(name string, line uint64, col uint64, names []string) Error {
+	if s := closest_name(name, names); s != "" {
+		return Error{code: Code_Undefined_Variable, kind: "erro2", key: "erro msg17", args: []string{name, s}, line: line, col: col}
+	}
+	return Error{code: Code_Undefined_Variable, kind: "erro2", key: "erro msg5", args: []string{name}, line: line, col: col}
+}
+
+// missing_attr returns the error of using the attribute name of value
+// when value doesn't have it, or nil.
+func missing_attr(value Value, name string, line uint64, col uint64) any {
+	names, ok := Attr_Names(value)
+	if !ok {
+		return nil
+	}
+	for _, n := range names {
+		if n == name {
+			return nil
+		}
+	}
+	re := Error{code: Code_Missing_Attribute, kind: "erro3", key: "erro msg18", args: []string{value.VType(), name}, line: line, col: col}
+	if s := closest_name(name, names); s != "" {
+		re.key, re.args = "erro msg19", append(re.args, s)
+	} else if len(names) > 0 {
+		sorted := append([]string{}, names...)
+		sort.Strings(sorted)
+		re.key, re.args = "erro msg20", append(re.args, strings.Join(sorted, ", "))
+	}
+	return re
+}
+
+// read_attr reads the attribute name of value. It is an error when value
+// lists its attributes and name isn't one of them.
+func read_attr(value Value, name string, line uint64, col uint64) (Value, any) {
+	re := value.On_get_attr(name)
+	if re.VType() == "Null" {
+		if err := missing_attr(value, name, line, col); err != nil {
+			return nil, err
+		}
+	}
+	return re, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package kll
+
+// raw_mode is only implemented for linux; elsewhere the repl reads whole
+// lines, without history keys or completion.
+func raw_mode(fd uintptr) (func(), bool) {
+	return nil, false
+}
@@ -0,0 +1,198 @@
+package kll
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Call_Frame is a line of a traceback: the function that was running, the
+// module it was declared in and the position it had reached. Line is 0
+// for GoFunctions.
+type Call_Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module"`
+	Line     uint64 `json:"line"`
+	Col      uint64 `json:"col"`
+}
+
+// call is pushed on Interpreter.calls while a function runs. line and col
+// are where it was called from, 0 when a GoFunction called it.
+type call struct {
+	callee Value
+	expr   Value
+	caller string
+	line   uint64
+	col    uint64
+}
+
+// name is the name of the called function or, for GoFunctions and
+// anonymous functions, of the expression it was called through.
+func (this call) name() string {
+	switch f := this.callee.(type) {
+	case Function:
+		if f.name != "" {
+			return f.name
+		}
+	case Closure:
+		if f.code.name != "" {
+			return f.code.name
+		}
+	}
+	if name := call_name(this.expr); name != "" {
+		return name
+	}
+	return "<anonymous>"
+}
+func (this call) module() string {
+	switch f := this.callee.(type) {
+	case Function:
+		return f.module
+	case Closure:
+		return f.module
+	}
+	return "<go>"
+}
+func call_name(expr Value) string {
+	node, ok := expr.(Node)
+	if !ok {
+		return ""
+	}
+	switch node.Tp {
+	case "var":
+		return node.Value[0].Re_string("")
+	case "get attr":
+		path := strings.Join(attr_path(node.Value[1]), ".")
+		if x := call_name(node.Value[0]); x != "" {
+			return x + "." + path
+		}
+		return path
+	}
+	return ""
+}
+
+// push_call is done by the call sites before calling callee. Functions and
+// Closures called by a GoFunction push their own call, with no position.
+func (this *Interpreter) push_call(callee Value, expr Value, line uint64, col uint64) {
+	switch callee.(type) {
+	case Function, Closure:
+		this.entering = true
+	}
+	this.calls = append(this.calls, call{callee: callee, expr: expr, caller: this.module, line: line, col: col})
+}
+
+// entered tells a Function or Closure being called whether a call site
+// pushed its call.
+func (this *Interpreter) entered() bool {
+	re := this.entering
+	this.entering = false
+	return re
+}
+
+// end_call pops the innermost call. An error leaving it gets the traceback
+// of the calls running when it was raised, unless an inner call already
+// gave it one. A generator being stopped unwinds its body while its calls
+// are no longer on the stack, so nothing is popped for it.
+func (this *Interpreter) end_call(err any) any {
+	if is_generator_exit(err) {
+		return err
+	}
+	this.entering = false
+	if err != nil {
+		err = this.with_traceback(err)
+	}
+	this.calls = this.calls[:len(this.calls)-1]
+	return err
+}
+func (this *Interpreter) with_traceback(err any) any {
+	if traceback_of(err) != nil {
+		return err
+	}
+	module := this.module
+	if len(this.calls) > 0 {
+		module = this.calls[0].caller
+	}
+	tb := []Call_Frame{{Function: "<module>", Module: module}}
+	if len(this.calls) > 0 && this.calls[0].line == 0 {
+		// called by the embedder, not by a script
+		tb = []Call_Frame{}
+	}
+	for _, c := range this.calls {
+		if len(tb) > 0 {
+			tb[len(tb)-1].Line, tb[len(tb)-1].Col = c.line, c.col
+		}
+		tb = append(tb, Call_Frame{Function: c.name(), Module: c.module()})
+	}
+	if tb[len(tb)-1].Module != "<go>" {
+		tb[len(tb)-1].Line, tb[len(tb)-1].Col = error_position(err)
+	}
+	if e, ok := err.(Error); ok {
+		e.traceback = tb
+		return e
+	}
+	return Error{other_error: err, traceback: tb}
+}
+
+// error_position is the position of the innermost error of the chain that
+// has one.
+func error_position(err any) (uint64, uint64) {
+	var line, col uint64
+	for {
+		e, ok := err.(Error)
+		if !ok {
+			return line, col
+		}
+		if e.line != 0 {
+			line, col = e.line, e.col
+		}
+		err = e.other_error
+	}
+}
+func traceback_of(err any) []Call_Frame {
+	for {
+		e, ok := err.(Error)
+		if !ok {
+			return nil
+		}
+		if e.traceback != nil {
+			return e.traceback
+		}
+		err = e.other_error
+	}
+}
+
+// Traceback lists the calls that were running when a runtime error was
+// raised, outermost first. It is nil for syntax errors.
+func (this Error) Traceback() []Call_Frame {
+	return traceback_of(this)
+}
+
+// conv_traceback renders a runtime error the way Python does, showing the
+// source of the frames of the module lines belongs to.
+func conv_traceback(e Error, tb []Call_Frame) string {
+	re := lang_text_in(e.locale, "traceback", nil) + "\n"
+	for _, f := range tb {
+		module := f.Module
+		if module == "" {
+			module = "<string>"
+		}
+		if f.Line == 0 {
+			re += lang_text_in(e.locale, "traceback go frame", []string{module, f.Function}) + "\n"
+			continue
+		}
+		re += lang_text_in(e.locale, "traceback frame", []string{module, fmt.Sprint(f.Line), f.Function}) + "\n"
+		if f.Module == tb[0].Module && f.Line <= uint64(len(e.lines)) {
+			re += "    " + strings.TrimSpace(e.lines[f.Line-1]) + "\n"
+		}
+	}
+	var err any = e
+	for {
+		v, ok := err.(Error)
+		if !ok {
+			return re + fmt.Sprint(err)
+		}
+		if v.other_error == nil {
+			return re + v.message()
+		}
+		err = v.other_error
+	}
+}
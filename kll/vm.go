@@ -0,0 +1,384 @@
+package kll
+
+// Closure is a function compiled for the VM. Its own variables live in the
+// slots of a frame created per call, and env links it to the frames of the
+// code it was declared in.
+type Closure struct {
+	VTp    string `json:"value type"`
+	code   *Bytecode
+	env    *frame
+	inter  *Interpreter
+	locals *Object
+	module string
+}
+
+var null_value = Create_Null()
+
+func (this Closure) On_sum(value Value) Value {
+	return Create_Null()
+}
+func (this Closure) On_sub(value Value) Value {
+	return Create_Null()
+}
+func (this Closure) On_div(value Value) Value {
+	return Create_Null()
+}
+func (this Closure) On_mul(value Value) Value {
+	return Create_Null()
+}
+func (this Closure) Re_string(prefix string) string {
+	if this.code.name == "" {
+		return "<function>"
+	}
+	return "<function " + this.code.name + ">"
+}
+func (this Closure) Re_number() float64 {
+	return -1
+}
+func (this Closure) Re_bool() bool {
+	return false
+}
+func (this Closure) VType() string {
+	return "Function"
+}
+func (this Closure) On_call(args []Value, kwargs map[string]*Variable, pos int) (Value, any) {
+	from_go := !this.inter.entered()
+	env := this.bind(args, kwargs)
+	if this.code.generator {
+		return create_generator(this.inter, func(gen *generator_state) (Value, any) {
+			env.gen = gen
+			return Closure{code: this.code, inter: this.inter, locals: this.locals, module: this.module}.run(env, true)
+		}), nil
+	}
+	return this.run(env, from_go)
+}
+
+// bind creates the frame of a call, with the arguments in the slots of the
+// parameters. args is copied, so the VM can pass the top of its stack.
+func (this Closure) bind(args []Value, kwargs map[string]*Variable) *frame {
+	env := new_frame(this.code.slots, this.env)
+	for i, name := range this.code.params {
+		if i < len(args) {
+			env.slots[i] = args[i]
+		}
+		if v, ok := kwargs[name]; ok {
+			env.slots[i] = v.Value
+		}
+	}
+	return env
+}
+func (this Closure) run(env *frame, from_go bool) (Value, any) {
+	if from_go {
+		this.inter.push_call(this, nil, 0, 0)
+	}
+	module := this.inter.module
+	this.inter.module = this.module
+	re, err := this.inter.run_bytecode(this.code, env, this.locals)
+	if is_generator_exit(err) {
+		return re, err
+	}
+	this.inter.module = module
+	if from_go {
+		err = this.inter.end_call(err)
+	}
+	return re, err
+}
+func (this Closure) On_get_attr(name string) Value {
+	return Create_Null()
+}
+func (this Closure) Attr_Names() []string {
+	return []string{}
+}
+func (this Closure) On_set_attr(name string, value Value) Value {
+	return Create_Null()
+}
+func (this Closure) On_in(name Value) Value {
+	return Create_Bool(false)
+}
+func (this Closure) Re_hash() (string, bool) {
+	return "", false
+}
+
+func create_closure(code *Bytecode, env *frame, inter *Interpreter, locals *Object) Value {
+	re := Closure{code: code, env: env, inter: inter, locals: locals, module: inter.module}
+	re.VTp = re.VType()
+	return re
+}
+
+// run_bytecode is the VM loop. env holds the slots of the running code;
+// the names left unresolved are looked up in locals and Globals.
+func (this *Interpreter) run_bytecode(code *Bytecode, env *frame, locals *Object) (Value, any) {
+	stack := make([]Value, 0, 16)
+	iters := []Iterator{}
+	fail := func(err any) (Value, any) {
+		for i := len(iters) - 1; i >= 0; i-- {
+			iters[i].On_close()
+		}
+		return nil, err
+	}
+	for ip := 0; ip < len(code.code); ip++ {
+		in := &code.code[ip]
+		top := len(stack) - 1
+		switch in.op {
+		case op_const:
+			stack = append(stack, code.consts[in.a])
+		case op_null:
+			stack = append(stack, null_value)
+		case op_pop:
+			stack = stack[:top]
+		case op_dup:
+			stack = append(stack, stack[top])
+		case op_load_slot:
+			v := env.at(int(in.b)).slots[in.a]
+			if v == nil {
+				var err any
+				if v, err = this.load_slot(env, int(in.b), int(in.a), locals, uint64(in.line), uint64(in.col)); err != nil {
+					return fail(err)
+				}
+			}
+			stack = append(stack, v)
+		case op_store_slot:
+			f := env.at(int(in.b))
+			if f.slots[in.a] != nil {
+				f.set(int(in.a), stack[top])
+			} else if err := this.store_slot(env, int(in.b), int(in.a), stack[top], locals, uint64(in.line), uint64(in.col)); err != nil {
+				return fail(err)
+			}
+			stack = stack[:top]
+		case op_def_slot:
+			env.set(int(in.a), stack[top])
+			stack = stack[:top]
+		case op_load_name, op_load_name_mut:
+			name := code.consts[in.a].(String).Value
+			scope := this.find_scope(locals, name)
+			if scope == nil {
+				return fail(var_error(name, uint64(in.line), uint64(in.col), this.hinted_names(code, in, locals)))
+			}
+			if in.op == op_load_name_mut && scope.is_const(name) {
+				stack = append(stack, null_value)
+			} else {
+				stack = append(stack, scope.On_get_attr(name))
+			}
+		case op_store_name:
+			name := code.consts[in.a].(String).Value
+			scope := this.find_scope(locals, name)
+			if scope == nil {
+				return fail(var_error(name, uint64(in.line), uint64(in.col), this.hinted_names(code, in, locals)))
+			}
+			if !scope.is_const(name) {
+				scope.On_set_attr(name, stack[top])
+			}
+			stack = stack[:top]
+		case op_def_name:
+			locals.Create_Var(code.consts[in.a].(String).Value, 0, stack[top], false)
+			stack = stack[:top]
+		case op_def_global:
+			this.Globals.Create_Var(code.consts[in.a].(String).Value, 0, stack[top], false)
+			stack = stack[:top]
+		case op_exist:
+			stack = append(stack, Create_Bool(this.find_scope(locals, code.consts[in.a].(String).Value) != nil))
+		case op_get_attr:
+			// b is 1 for reads, where a missing attribute is an error, and 0 on
+			// the way to an assignment
+			if in.b == 0 {
+				stack[top] = stack[top].On_get_attr(code.consts[in.a].(String).Value)
+				break
+			}
+			v, err := read_attr(stack[top], code.consts[in.a].(String).Value, uint64(in.line), uint64(in.col))
+			if err != nil {
+				return fail(err)
+			}
+			stack[top] = v
+		case op_get_method:
+			v, err := read_attr(stack[top], code.consts[in.a].(String).Value, uint64(in.line), uint64(in.col))
+			if err != nil {
+				return fail(err)
+			}
+			stack[top] = v
+		case op_set_attr:
+			stack[top].On_set_attr(code.consts[in.a].(String).Value, stack[top-1])
+			stack = stack[:top-1]
+		case op_add, op_sub, op_mul, op_div, op_eq, op_in, op_and, op_or:
+			v1, v2 := stack[top-1], stack[top]
+			stack = stack[:top]
+			switch in.op {
+			case op_add:
+				stack[top-1] = Sum(v1, v2)
+			case op_sub:
+				stack[top-1] = Sub(v1, v2)
+			case op_mul:
+				stack[top-1] = Mul(v1, v2)
+			case op_div:
+				stack[top-1] = Div(v1, v2)
+			case op_eq:
+				stack[top-1] = Create_Bool(Equal(v1, v2))
+			case op_in:
+				stack[top-1] = In(v2, v1)
+			case op_and:
+				stack[top-1] = Create_Bool(v1.Re_bool() && v2.Re_bool())
+			case op_or:
+				stack[top-1] = Create_Bool(v1.Re_bool() || v2.Re_bool())
+			}
+		case op_neg:
+			stack[top] = Create_Number(-stack[top].Re_number())
+		case op_range:
+			re, err := Range_Between(stack[top-1].Re_number(), stack[top].Re_number(), 1, in.a == 1)
+			if err != nil {
+				return fail(err)
+			}
+			stack = stack[:top]
+			stack[top-1] = re
+		case op_array:
+			n := int(in.a)
+			values := make([]Value, n)
+			copy(values, stack[len(stack)-n:])
+			stack = append(stack[:len(stack)-n], Create_Array(values))
+		case op_list_new:
+			stack = append(stack, Create_Array(nil))
+		case op_list_push:
+			list := stack[top-1].(Array)
+			*list.Value = append(list.Items(), stack[top])
+			stack = stack[:top]
+		case op_list_extend:
+			items, err := Collect(stack[top])
+			if err != nil {
+				return fail(Error{line: uint64(in.line), col: uint64(in.col), other_error: err})
+			}
+			list := stack[top-1].(Array)
+			*list.Value = append(list.Items(), items...)
+			stack = stack[:top]
+		case op_call, op_call_list:
+			callee := stack[top]
+			stack = stack[:top]
+			var kwargs map[string]*Variable
+			if in.b >= 0 {
+				kwargs = make(map[string]*Variable)
+				names := code.consts[in.b].(Tuple).Value
+				base := len(stack) - len(names)
+				for i, n := range names {
+					name := n.(String).Value
+					kwargs[name] = &Variable{Value: stack[base+i], name: name}
+				}
+				stack = stack[:base]
+			}
+			this.push_call(callee, code.calls[ip], uint64(in.line), uint64(in.col))
+			var re Value
+			var err any
+			if c, ok := callee.(Closure); ok && in.op == op_call && !c.code.generator {
+				// a closure takes its arguments from the stack, without
+				// the slice Call needs
+				n := int(in.a)
+				this.entering = false
+				re, err = c.run(c.bind(stack[len(stack)-n:], kwargs), false)
+				stack = stack[:len(stack)-n]
+			} else {
+				var args []Value
+				if in.op == op_call_list {
+					args = stack[len(stack)-1].(Array).Items()
+					stack = stack[:len(stack)-1]
+				} else {
+					n := int(in.a)
+					args = make([]Value, n)
+					copy(args, stack[len(stack)-n:])
+					stack = stack[:len(stack)-n]
+				}
+				re, err = Call(callee, args, kwargs, 0)
+			}
+			err = this.end_call(err)
+			if err != nil {
+				return fail(Error{line: uint64(in.line), col: uint64(in.col), other_error: err})
+			}
+			if re == nil {
+				re = null_value
+			}
+			stack = append(stack, re)
+		case op_jump:
+			ip = int(in.a) - 1
+		case op_jump_if_false:
+			if !stack[top].Re_bool() {
+				ip = int(in.a) - 1
+			}
+			stack = stack[:top]
+		case op_iter:
+			it, err := Iter(stack[top])
+			if err != nil {
+				return fail(Error{line: uint64(in.line), col: uint64(in.col), other_error: err})
+			}
+			stack[top] = it
+			iters = append(iters, it)
+		case op_for_next:
+			v, ok, err := stack[top].(Iterator).On_next()
+			if err != nil {
+				return fail(Error{line: uint64(in.line), col: uint64(in.col), other_error: err})
+			}
+			if !ok {
+				stack = stack[:top]
+				iters = iters[:len(iters)-1]
+				ip = int(in.a) - 1
+				break
+			}
+			stack = append(stack, v)
+		case op_enter:
+			env = env.iteration(int(in.a))
+		case op_leave:
+			env = env.parent
+		case op_unpack_next:
+			v, ok, err := stack[top].(Iterator).On_next()
+			if err != nil {
+				return fail(err)
+			}
+			if !ok {
+				v = null_value
+			}
+			stack = append(stack, v)
+		case op_unpack_rest:
+			rest, err := Collect(stack[top])
+			if err != nil {
+				return fail(err)
+			}
+			stack = append(stack, Create_Array(rest))
+		case op_iter_end:
+			stack[top].(Iterator).On_close()
+			stack = stack[:top]
+			iters = iters[:len(iters)-1]
+		case op_closure:
+			stack = append(stack, create_closure(code.protos[in.a], env, this, locals))
+		case op_return:
+			re := stack[top]
+			fail(nil)
+			return re, nil
+		case op_yield:
+			if env.gen == nil {
+				return fail(Error{code: Code_Yield_Outside_Generator, kind: "erro1", key: "erro msg14", line: uint64(in.line), col: uint64(in.col)})
+			}
+			v, err := env.gen.yield_value(stack[top])
+			if err != nil {
+				return fail(err)
+			}
+			stack[top] = v
+		case op_pointer:
+			name := code.consts[in.a].(String).Value
+			if scope := this.find_scope(locals, name); scope != nil {
+				v, _ := scope.On_get_Variable(name)
+				stack = append(stack, Create_Pointer(v))
+			} else {
+				stack = append(stack, null_value)
+			}
+		case op_fail:
+			return fail(code.errors[in.a])
+		}
+	}
+	return null_value, nil
+}
+
+// hinted_names lists the names the error of a missing variable can
+// suggest: the ones in locals and Globals and the slots compiler.hints kept.
+func (this *Interpreter) hinted_names(code *Bytecode, in *instruction, locals *Object) []string {
+	re := this.visible_names(locals)
+	if in.b > 0 {
+		for _, v := range code.consts[in.b-1].(Tuple).Value {
+			re = append(re, v.Re_string(""))
+		}
+	}
+	return re
+}
@@ -0,0 +1,263 @@
+package kll
+
+import (
+	"errors"
+	"testing"
+)
+
+var engine_tests = []struct {
+	name   string
+	source string
+	// result is what the last statement evaluates to, when code is empty.
+	result string
+	code   Code
+}{
+	{name: "arithmetic", source: `1 + 2 * 3`, result: "7"},
+	{name: "strings", source: `("ab" * 3) + "c".upper()`, result: "abababC"},
+	{name: "logic", source: `[1 == 1, 1 == 2, true && false, false || true]`, result: "[true, false, false, true]"},
+	{name: "if", source: `var s = ""
+if 1 == 1 {
+	s = "sim"
+}
+if 1 == 2 {
+	s = "nao"
+}
+s`, result: "sim"},
+	{name: "for", source: `var s = 0
+for i in 0..10 {
+	s = s + i
+}
+s`, result: "55"},
+	{name: "closure", source: `function counter() {
+	var n = 0
+	return function() {
+		n = n + 1
+		return n
+	}
+}
+var c = counter()
+c()
+c()
+c()`, result: "3"},
+	{name: "recursion", source: `function fib(n) {
+	if n in 0..1 {
+		return n
+	}
+	return fib(n - 1) + fib(n - 2)
+}
+fib(15)`, result: "610"},
+	{name: "keywords", source: `function f(a, b) {
+	return [a, b]
+}
+[f(1), f(1, b = 2), f(b = 3, a = 4)]`, result: "[[1, null], [1, 2], [4, 3]]"},
+	{name: "spread", source: `var a = [1, 2]
+[...a, 3, ...0..<2]`, result: "[1, 2, 3, 0, 1]"},
+	{name: "destructure", source: `var [a, [b, c], ...rest] = [1, [2, 3], 4, 5]
+[a, b, c, rest]`, result: "[1, 2, 3, [4, 5]]"},
+	{name: "objects", source: `var o = Object.from_entries([["a", 1]])
+o.b = 2
+[o.a, o.b, Object.keys(o)]`, result: `[1, 2, ["a", "b"]]`},
+	{name: "map", source: `var m = Map()
+m.set("a", 1)
+m.set(2, "b")
+[m.get("a"), m.get(2), m.size]`, result: `[1, "b", 2]`},
+	{name: "array methods", source: `[3, 1, 2].sort().map(function(x) { return x * 2 }).filter(function(x) { return x in 3..6 })`, result: "[4, 6]"},
+	{name: "range", source: `[range(0, 10, 3), (0..10).stop, 4 in 0..<10]`, result: "[range(0, 10, 3), 10, true]"},
+	{name: "generator", source: `function* g(n) {
+	for i in 0..<n {
+		var got = yield i
+	}
+	return "fim"
+}
+var it = g(3)
+[it.next(), it.next(), it.next(), it.next()]`, result: `[{done:false, value:0}, {done:false, value:1}, {done:false, value:2}, {done:true, value:"fim"}]`},
+	{name: "global", source: `function f() {
+	global g = 5
+}
+f()
+g`, result: "5"},
+	{name: "exist", source: `[exist console, exist nada]`, result: "[true, false]"},
+	{name: "before the declaration", source: `var p = Math.floor(1.5)
+var Math = 2
+[p, Math]`, result: "[1, 2]"},
+	{name: "exist guard", source: `var s = "sem"
+if exist nada {
+	s = nada
+}
+s`, result: "sem"},
+
+	{name: "undefined variable", source: `var a = 1
+a + nada`, code: Code_Undefined_Variable},
+	{name: "read before the declaration", source: `console.log(y)
+var y = 1`, code: Code_Undefined_Variable},
+	{name: "assigned before the declaration", source: `y = 2
+var y = 1`, code: Code_Undefined_Variable},
+	{name: "closures in a loop", source: `var fs = []
+for i in 0..<3 {
+	var d = i * 10
+	fs.push(function() { return i + d })
+}
+fs.map(function(f) { return f() })`, result: "[0, 11, 22]"},
+	{name: "closures in a loop of a function", source: `function make(n) {
+	var fs = []
+	for [k, v] in Object.entries(n) {
+		fs.push(function() { return k + v })
+	}
+	return fs
+}
+make(Object.from_entries([["a", "1"], ["b", "2"]])).map(function(f) { return f() })`, result: `["a1", "b2"]`},
+	{name: "closures in a loop of a generator", source: `function* gen() {
+	for i in 0..<3 {
+		var f = function() { return i * 2 }
+		yield f
+	}
+}
+[...gen()].map(function(f) { return f() })`, result: "[0, 2, 4]"},
+	{name: "undefined local", source: `function f() {
+	var total = 1
+	return totl
+}
+f()`, code: Code_Undefined_Variable},
+	{name: "not callable", source: `[1].map(2)`, code: Code_Not_Callable},
+	{name: "missing attribute", source: `"x".lenght`, code: Code_Missing_Attribute},
+	{name: "missing key", source: `var o = Object.from_entries([["alpha", 1]])
+o.alpah`, code: Code_Missing_Attribute},
+	{name: "missing method", source: `[1].pussh(2)`, code: Code_Missing_Attribute},
+	{name: "not iterable", source: `for i in 1 {
+}`, code: Code_Not_Iterable},
+	{name: "zero step", source: `range(0, 10, 0)`, code: Code_Zero_Step},
+	{name: "not hashable", source: `var m = Map()
+m.set([1, 2], 1)`, code: Code_Not_Hashable},
+	{name: "assertion", source: `function f() {
+	assert(1 == 2)
+}
+f()`, code: Code_Assertion_Failed},
+}
+
+// TestVMMatchesWalker runs each script on both engines, which must agree
+// on the result, or on the error and where it happened.
+func TestVMMatchesWalker(t *testing.T) {
+	for _, test := range engine_tests {
+		t.Run(test.name, func(t *testing.T) {
+			results := [2]string{}
+			for i, vm := range []bool{false, true} {
+				inter := Interpreter{Use_VM: vm, Locale: "en"}
+				inter.Init()
+				locals := Create_Object(nil).(Object)
+				v, err := inter.Eval(test.source, &locals)
+				if test.code == "" {
+					if err != nil {
+						t.Fatalf("vm=%v: %v", vm, err)
+					}
+					results[i] = v.Re_string("")
+					if results[i] != test.result {
+						t.Errorf("vm=%v: got %s, want %s", vm, results[i], test.result)
+					}
+					continue
+				}
+				if !errors.Is(err, test.code) {
+					t.Fatalf("vm=%v: got error %v, want %s", vm, err, test.code)
+				}
+				results[i] = err.Error()
+			}
+			if results[0] != results[1] {
+				t.Errorf("the engines disagree:\nwalker: %s\nvm:     %s", results[0], results[1])
+			}
+		})
+	}
+}
+
+// TestModuleVariables checks that the variables of a module, which have
+// slots, stay in step with the locals the scripts run with, by name.
+func TestModuleVariables(t *testing.T) {
+	for _, vm := range []bool{false, true} {
+		inter := Interpreter{Use_VM: vm}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		steps := []struct {
+			source string
+			result string
+		}{
+			{source: `var x = 1
+function get() {
+	return x
+}
+function set(v) {
+	x = v
+}`},
+			{source: `x = 2
+get()`, result: "2"},
+			{source: `set(3)
+x`, result: "3"},
+			{source: `var x = 4
+get()`, result: "4"},
+		}
+		for _, step := range steps {
+			v, err := inter.Eval(step.source, &locals)
+			if err != nil {
+				t.Fatalf("vm=%v: %v", vm, err)
+			}
+			if step.result != "" && v.Re_string("") != step.result {
+				t.Fatalf("vm=%v: %s gave %s, want %s", vm, step.source, v.Re_string(""), step.result)
+			}
+		}
+		if got := locals.On_get_attr("x").Re_string(""); got != "4" {
+			t.Errorf("vm=%v: locals has x = %s, want 4", vm, got)
+		}
+		locals.On_set_attr("x", Create_Number(5))
+		if v, err := inter.Eval(`get()`, &locals); err != nil || v.Re_string("") != "5" {
+			t.Errorf("vm=%v: after setting x in locals, get() gave %v, %v, want 5", vm, v, err)
+		}
+		locals.Delete_Var("x")
+		if _, err := inter.Eval(`get()`, &locals); !errors.Is(err, Code_Undefined_Variable) {
+			t.Errorf("vm=%v: after deleting x from locals, got %v, want %s", vm, err, Code_Undefined_Variable)
+		}
+	}
+}
+
+// TestUndefinedBeforeRunning checks that a name nothing defines is
+// reported before the script runs any of its statements.
+func TestUndefinedBeforeRunning(t *testing.T) {
+	for _, vm := range []bool{false, true} {
+		inter := Interpreter{Use_VM: vm}
+		inter.Init()
+		locals := Create_Object(nil).(Object)
+		_, err := inter.Eval(`var x = 1
+function f() {
+	return nada
+}
+var y = f`, &locals)
+		if !errors.Is(err, Code_Undefined_Variable) {
+			t.Fatalf("vm=%v: got %v, want %s", vm, err, Code_Undefined_Variable)
+		}
+		if _, ok := locals.value.Get("x"); ok {
+			t.Errorf("vm=%v: x was declared before the error", vm)
+		}
+	}
+}
+
+func benchmark_engine(b *testing.B, vm bool) {
+	for _, script := range Benchmark_Scripts {
+		nodes, err := (&Parser{}).Parse(script.Source)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(script.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				v, err := run_benchmark(nodes, vm)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if v.Re_string("") != script.Result {
+					b.Fatalf("got %s, want %s", v.Re_string(""), script.Result)
+				}
+			}
+		})
+	}
+}
+func BenchmarkWalker(b *testing.B) {
+	benchmark_engine(b, false)
+}
+func BenchmarkVM(b *testing.B) {
+	benchmark_engine(b, true)
+}
@@ -1,32 +1,595 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/kaklikOf13/kll"
+	"github.com/kaklikOf13/kll/format"
+	"github.com/kaklikOf13/kll/lsp"
 )
 
+// exit codes
+const (
+	exit_ok    = 0
+	exit_fail  = 1
+	exit_usage = 2
+)
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(fs *flag.FlagSet, args []string) int
+}
+
+var commands = []command{
+	{"run", "run [--vm] [--debug] [--optimize] [arquivo|-] [-- args...]", "roda o programa (main.kll por padrão, - lê da entrada)", cmd_run},
+	{"eval", "eval [--vm] -e codigo", "roda o codigo e mostra o valor dele", cmd_eval},
+	{"check", "check [--json] arquivo|-...", "procura erros de sintaxe e variaveis que não existem, sem rodar", cmd_check},
+	{"tokens", "tokens [--json] arquivo|-", "mostra os tokens do lexer", cmd_tokens},
+	{"ast", "ast [--json] arquivo|-", "mostra os nós do parser", cmd_ast},
+	{"fmt", "fmt [--check] [--write] arquivo|pasta|-...", "formata os arquivos .kll, mostrando o resultado", cmd_fmt},
+	{"lint", "lint [--json] [--disable regras] [--only regras] arquivo|pasta|-...", "procura codigo que roda mas provavelmente está errado", cmd_lint},
+	{"test", "test [--vm] [arquivo|pasta...]", "roda os arquivos *_test.kll e as funções test_ deles", cmd_test},
+	{"lsp", "lsp", "servidor de Language Server Protocol na entrada e saída padrão, para os editores", cmd_lsp},
+	{"repl", "repl", "roda as linhas digitadas, guardando o historico em ~/.kll_history", cmd_repl},
+	{"bench", "bench", "compara a VM com o interpretador de arvore", cmd_bench},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, "uso: kll comando [flags] [args]\n\ncomandos:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "   %s:\n      %s\n", c.name, c.help)
+	}
+	fmt.Fprint(w, "\nkll comando --help mostra as flags do comando\n\nvariaveis:\n   KLL_LANG:\n      lingua das mensagens (pt-br, en)\n")
+}
+
 func main() {
-	i := kll.Interpreter{}
-	if len(os.Args) == 1 {
-		print("comandos:\n   run:\n      roda o programa\n")
-		return
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stdout)
+		return exit_ok
+	}
+	name := args[0]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return exit_ok
+	case "debug-run":
+		name, args = "run", append([]string{"run", "--debug"}, args[1:]...)
+	case "vm-run":
+		name, args = "run", append([]string{"run", "--vm"}, args[1:]...)
+	}
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "uso: kll %s\n\n%s\n", c.usage, c.help)
+			fs.PrintDefaults()
+		}
+		return c.run(fs, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "o comando '%s' não existe\n\n", name)
+	usage(os.Stderr)
+	return exit_usage
+}
+
+// parse parses the flags of a command, returning the exit code when the
+// command shouldn't go on.
+func parse(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exit_ok, false
+		}
+		return exit_usage, false
+	}
+	return 0, true
+}
+
+func create_interpreter() *kll.Interpreter {
+	return &kll.Interpreter{Locale: os.Getenv("KLL_LANG")}
+}
+
+// read_source reads the script at path, or the standard input when path
+// is "-", and returns it with the name it is shown with.
+func read_source(path string) (string, string, error) {
+	if path == "-" {
+		txt, err := io.ReadAll(os.Stdin)
+		return string(txt), "<stdin>", err
+	}
+	txt, err := os.ReadFile(path)
+	return string(txt), path, err
+}
+
+func cmd_run(fs *flag.FlagSet, args []string) int {
+	vm := fs.Bool("vm", false, "usa a VM de bytecode")
+	debug := fs.Bool("debug", false, "mostra os nós e o bytecode")
+	optimize := fs.Bool("optimize", false, "otimiza os nós antes de rodar")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	path := "main.kll"
+	rest := fs.Args()
+	if len(rest) > 0 && rest[0] != "--" {
+		path, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+	txt, name, err := read_source(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exit_fail
+	}
+	i := create_interpreter()
+	i.Use_VM, i.Debug, i.Optimize, i.Args = *vm, *debug, *optimize, rest
+	_, err = i.Exec_Main_Text(txt, name)
+	return report(i, err, txt)
+}
+
+// report prints the error a script ended with and returns the exit code
+// for it: the one given to process.exit, or 1 for any other error.
+func report(i *kll.Interpreter, err error, txt string) int {
+	var exit kll.Exit
+	if errors.As(err, &exit) {
+		return exit.Code
 	}
-	if os.Args[1] == "run" || os.Args[1] == "debug-run" {
-		if os.Args[1] == "debug-run" {
-			i.Debug = true
+	if err != nil {
+		fmt.Fprint(os.Stderr, i.Format_Error(err, txt))
+		return exit_fail
+	}
+	return exit_ok
+}
+
+func cmd_eval(fs *flag.FlagSet, args []string) int {
+	src := fs.String("e", "", "o codigo")
+	vm := fs.Bool("vm", false, "usa a VM de bytecode")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if *src == "" {
+		fs.Usage()
+		return exit_usage
+	}
+	i := create_interpreter()
+	i.Use_VM, i.Args = *vm, fs.Args()
+	re, err := i.Exec_Main_Text(*src, "<eval>")
+	if err == nil && re.VType() != "Null" {
+		fmt.Println(re.Re_string(""))
+	}
+	return report(i, err, *src)
+}
+
+func cmd_check(fs *flag.FlagSet, args []string) int {
+	as_json := fs.Bool("json", false, "escreve os erros em JSON")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exit_usage
+	}
+	type result struct {
+		File        string           `json:"file"`
+		Diagnostics []kll.Diagnostic `json:"diagnostics"`
+	}
+	results := []result{}
+	code := exit_ok
+	for _, path := range fs.Args() {
+		txt, name, err := read_source(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exit_fail
+			continue
+		}
+		errs := create_interpreter().Check(txt)
+		if len(errs) > 0 {
+			code = exit_fail
+		}
+		if *as_json {
+			results = append(results, result{File: name, Diagnostics: kll.Diagnostics(errs)})
+			continue
 		}
-		if len(os.Args) >= 3 {
-			i.Exec_Main(os.Args[2])
+		for _, e := range errs {
+			fmt.Print(name + ": " + create_interpreter().Format_Error(e, txt))
+		}
+	}
+	if *as_json {
+		json.NewEncoder(os.Stdout).Encode(results)
+	}
+	return code
+}
+
+func cmd_tokens(fs *flag.FlagSet, args []string) int {
+	as_json := fs.Bool("json", false, "escreve os tokens em JSON")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exit_usage
+	}
+	txt, _, err := read_source(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exit_fail
+	}
+	var l kll.Lexer
+	tokens, err2 := l.Tokenizer(txt)
+	if err2 != nil {
+		fmt.Fprint(os.Stderr, create_interpreter().Format_Error(err2, txt))
+		return exit_fail
+	}
+	type token struct {
+		Type  string   `json:"type"`
+		Value *string  `json:"value,omitempty"`
+		Span  kll.Span `json:"span"`
+	}
+	list := []token{}
+	code := exit_ok
+	for _, t := range tokens {
+		tk := token{Type: t.Type(), Span: t.Span()}
+		if v := t.Value(); v != nil {
+			s := v.Re_string("")
+			tk.Value = &s
+		}
+		if e := t.Err(); e != nil {
+			s := e.(kll.Error).Message()
+			tk.Value = &s
+			code = exit_fail
+		}
+		list = append(list, tk)
+	}
+	if *as_json {
+		json.NewEncoder(os.Stdout).Encode(list)
+		return code
+	}
+	for _, t := range list {
+		pos := fmt.Sprintf("%d:%d-%d:%d", t.Span.Start.Line, t.Span.Start.Col, t.Span.End.Line, t.Span.End.Col)
+		if t.Value != nil {
+			fmt.Printf("%-14s %-12s %q\n", pos, t.Type, *t.Value)
 		} else {
-			i.Exec_Main("main.kll")
+			fmt.Printf("%-14s %s\n", pos, t.Type)
+		}
+	}
+	return code
+}
+
+func cmd_ast(fs *flag.FlagSet, args []string) int {
+	as_json := fs.Bool("json", false, "escreve os nós em JSON")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exit_usage
+	}
+	txt, _, err := read_source(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exit_fail
+	}
+	p := kll.Parser{Locale: os.Getenv("KLL_LANG")}
+	nodes, err2 := p.Parse(txt)
+	if errs, ok := err2.(kll.Errors); ok && len(errs) > 0 {
+		fmt.Fprint(os.Stderr, create_interpreter().Format_Error(errs, txt))
+		return exit_fail
+	}
+	if *as_json {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		e.Encode(nodes)
+		return exit_ok
+	}
+	for _, n := range nodes {
+		fmt.Println(n.Re_string("  "))
+	}
+	return exit_ok
+}
+
+// find_files lists the files of paths, looking inside the folders for the
+// ones whose name ends with suffix.
+func find_files(paths []string, suffix string) ([]string, error) {
+	re := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			re = append(re, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(p, suffix) {
+				re = append(re, p)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(re)
+	return re, nil
+}
+
+func cmd_test(fs *flag.FlagSet, args []string) int {
+	vm := fs.Bool("vm", false, "usa a VM de bytecode")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := find_files(paths, "_test.kll")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exit_fail
+	}
+	passed, failed := 0, 0
+	for _, file := range files {
+		txt, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+		i := create_interpreter()
+		i.Use_VM = *vm
+		module, err := i.Exec_Module(file)
+		if err != nil {
+			fmt.Printf("FAIL  %s\n%s", file, i.Format_Error(err, string(txt)))
+			failed++
+			continue
+		}
+		locals := module.(kll.Object)
+		for _, name := range locals.Keys() {
+			f := locals.On_get_attr(name)
+			if !strings.HasPrefix(name, "test_") || f.VType() != "Function" {
+				continue
+			}
+			if _, err := kll.Call(f, nil, map[string]*kll.Variable{}, 0); err != nil {
+				fmt.Printf("FAIL  %s  %s\n%s", file, name, i.Format_Error(err, string(txt)))
+				failed++
+				continue
+			}
+			fmt.Printf("ok    %s  %s\n", file, name)
+			passed++
+		}
+	}
+	fmt.Printf("%d ok, %d falharam\n", passed, failed)
+	if failed > 0 {
+		return exit_fail
+	}
+	return exit_ok
+}
+
+// source_files lists the .kll files of paths, looking inside the folders;
+// "-" is kept for the standard input.
+func source_files(paths []string) ([]string, error) {
+	re := []string{}
+	for _, p := range paths {
+		if p == "-" {
+			re = append(re, p)
+			continue
+		}
+		files, err := find_files([]string{p}, ".kll")
+		if err != nil {
+			return nil, err
+		}
+		re = append(re, files...)
+	}
+	return re, nil
+}
+
+func cmd_lint(fs *flag.FlagSet, args []string) int {
+	as_json := fs.Bool("json", false, "escreve os avisos em JSON")
+	disable := fs.String("disable", "", "regras que não rodam, separadas por virgula")
+	only := fs.String("only", "", "roda só essas regras, separadas por virgula")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exit_usage
+	}
+	config := kll.Lint_Config{Disabled: map[string]bool{}}
+	rules := map[string]bool{}
+	names := []string{}
+	for _, r := range kll.Lint_Rules {
+		rules[r.Name] = true
+		names = append(names, r.Name)
+	}
+	split := func(list string) ([]string, bool) {
+		re := []string{}
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if !rules[name] {
+				fmt.Fprintf(os.Stderr, "a regra '%s' não existe, as regras são: %s\n", name, strings.Join(names, ", "))
+				return nil, false
+			}
+			re = append(re, name)
+		}
+		return re, true
+	}
+	off, ok := split(*disable)
+	if !ok {
+		return exit_usage
+	}
+	for _, name := range off {
+		config.Disabled[name] = true
+	}
+	if *only != "" {
+		on, ok := split(*only)
+		if !ok {
+			return exit_usage
+		}
+		for _, name := range names {
+			config.Disabled[name] = true
+		}
+		for _, name := range on {
+			config.Disabled[name] = false
 		}
 	}
+	files, err := source_files(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exit_fail
+	}
+	type result struct {
+		File        string           `json:"file"`
+		Diagnostics []kll.Diagnostic `json:"diagnostics"`
+	}
+	results := []result{}
+	code := exit_ok
+	for _, path := range files {
+		txt, name, err := read_source(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exit_fail
+			continue
+		}
+		i := create_interpreter()
+		errs := i.Lint(txt, config)
+		if len(errs) > 0 {
+			code = exit_fail
+		}
+		if *as_json {
+			results = append(results, result{File: name, Diagnostics: kll.Diagnostics(errs)})
+			continue
+		}
+		for _, e := range errs {
+			fmt.Print(name + ": " + i.Format_Error(e, txt))
+		}
+	}
+	if *as_json {
+		json.NewEncoder(os.Stdout).Encode(results)
+	}
+	return code
+}
 
+func cmd_fmt(fs *flag.FlagSet, args []string) int {
+	check := fs.Bool("check", false, "só lista os arquivos que não estão formatados")
+	write := fs.Bool("write", false, "escreve o resultado no arquivo em vez de mostrar")
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exit_usage
+	}
+	files, err := source_files(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exit_fail
+	}
+	code := exit_ok
+	for _, path := range files {
+		txt, name, err := read_source(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exit_fail
+			continue
+		}
+		re, err := format.Source(txt)
+		if err != nil {
+			if errs, ok := err.(kll.Errors); ok {
+				fmt.Fprint(os.Stderr, name+": "+create_interpreter().Format_Error(errs, txt))
+			} else {
+				fmt.Fprintln(os.Stderr, name+":", err)
+			}
+			code = exit_fail
+			continue
+		}
+		switch {
+		case *check:
+			if re != txt {
+				fmt.Println(name)
+				code = exit_fail
+			}
+		case *write && path != "-":
+			if re == txt {
+				continue
+			}
+			if err := os.WriteFile(path, []byte(re), 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = exit_fail
+			}
+		default:
+			fmt.Print(re)
+		}
+	}
+	return code
+}
+
+func cmd_repl(fs *flag.FlagSet, args []string) int {
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	history := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		history = filepath.Join(home, ".kll_history")
+	}
+	i := create_interpreter()
+	return report(i, i.Repl(os.Stdin, os.Stdout, history), "")
+}
+
+func cmd_lsp(fs *flag.FlagSet, args []string) int {
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	s := &lsp.Server{Locale: os.Getenv("KLL_LANG")}
+	if err := s.Serve(os.Stdin, os.Stdout); err != nil {
+		if err != lsp.Err_Exit_Without_Shutdown {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return exit_fail
+	}
+	return exit_ok
+}
+
+func cmd_bench(fs *flag.FlagSet, args []string) int {
+	if code, ok := parse(fs, args); !ok {
+		return code
+	}
+	results, err := kll.Run_Benchmarks(kll.Benchmark_Scripts)
+	for _, r := range results {
+		fmt.Printf("%-12s arvore: %12d ns/op  vm: %12d ns/op  %.2fx\n", r.Name, r.Walker.Nanoseconds(), r.VM.Nanoseconds(), float64(r.Walker)/float64(r.VM))
+	}
+	if err != nil {
+		fmt.Println(err)
+		return exit_fail
+	}
+	return exit_ok
 }
 
 //go run main.go run
-//go run main.go debug-run
+//go run main.go run --vm
+//go run main.go eval -e "1 + 2"
+//go run main.go check main.kll
+//go run main.go fmt --write .
+//go run main.go lint --disable shadowed-variable .
+//go run main.go test
+//go run main.go repl
+//go run main.go lsp
+//go run main.go bench
 //go build -buildmode=c-shared -o kll.so main.go
 //go build main.go
 //GOOS=windows GOARCH=amd64 go build -o kll.exe main.go